@@ -0,0 +1,146 @@
+package sns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hinha/go-social-network/store"
+)
+
+// StoreKeyCheckpoint prefixes the keys of pagination checkpoints, followed
+// by the checkpoint kind, the Config.SearchMode, SearchTab, Lang and Date
+// window and the query, e.g.
+// "twitter:checkpoint:search:keyword:latest:id:2021-01-01..2021-12-31:golang".
+const StoreKeyCheckpoint = "twitter:checkpoint:"
+
+// CheckpointKind names the scrape a checkpoint belongs to.
+type CheckpointKind string
+
+const (
+	CheckpointSearch CheckpointKind = "search"
+	CheckpointUser   CheckpointKind = "user"
+)
+
+// Checkpoint is the pagination state of a TweetSearch or TweetUser call,
+// saved after every page to the checkpoint store so that Resume can
+// continue a crashed scrape instead of starting over.
+type Checkpoint struct {
+	Kind CheckpointKind `json:"kind"`
+	// Query is the search query or the screen name.
+	Query    string `json:"query"`
+	Endpoint string `json:"endpoint"`
+	// Params are the pagination parameters of the endpoint, without cursor.
+	Params url.Values `json:"params"`
+	// Cursor of the next page to fetch.
+	Cursor string `json:"cursor"`
+	// Count of results emitted so far, MaxTweets the limit of the call.
+	Count       int       `json:"count"`
+	MaxTweets   int       `json:"max_tweets"`
+	LastTweetID int       `json:"last_tweet_id"`
+	Done        bool      `json:"done"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// checkpointKey is the store key of the checkpoint of query, scrapers of
+// different search settings or date windows do not share checkpoints.
+func (c *TwitterScraper) checkpointKey(kind CheckpointKind, query string) string {
+	var window string
+	if since, until := c.dateWindow(); !since.IsZero() {
+		window = since.Format(dateLayout) + ".." + until.AddDate(0, 0, -1).Format(dateLayout)
+	}
+	return StoreKeyCheckpoint + strings.Join([]string{string(kind), c.config.SearchMode.String(),
+		c.config.SearchTab.String(), c.config.Lang.code(), window, query}, ":")
+}
+
+func (c *TwitterScraper) newCheckpoint(kind CheckpointKind, query, endpoint string, params url.Values, maxTweets int) *Checkpoint {
+	if c.checkpoints == nil {
+		return nil
+	}
+	copied := url.Values{}
+	for k, v := range params {
+		copied[k] = v
+	}
+	copied.Del("cursor")
+	return &Checkpoint{Kind: kind, Query: query, Endpoint: endpoint, Params: copied, MaxTweets: maxTweets}
+}
+
+// saveCheckpoint stores cp, failures are logged and never stop the scrape.
+func (c *TwitterScraper) saveCheckpoint(cp *Checkpoint) {
+	if cp == nil {
+		return
+	}
+	cp.UpdatedAt = time.Now()
+	raw, err := json.Marshal(cp)
+	if err == nil {
+		// the page is done even when ctx was just cancelled
+		err = c.checkpoints.Set(context.Background(), c.checkpointKey(cp.Kind, cp.Query), raw, 0)
+	}
+	if err != nil {
+		c.config.Logger.Error(time.Now(), "save checkpoint: ", err)
+	}
+}
+
+// LoadCheckpoint returns the last checkpoint saved for the query or screen
+// name with the search settings and Config.Date window of the scraper, or
+// store.ErrNotFound.
+func (c *TwitterScraper) LoadCheckpoint(ctx context.Context, kind CheckpointKind, query string) (*Checkpoint, error) {
+	if c.checkpoints == nil {
+		return nil, store.ErrNotFound
+	}
+	raw, err := c.checkpoints.Get(ctx, c.checkpointKey(kind, query))
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// DeleteCheckpoint forgets the checkpoint of the query or screen name, so
+// that the next TweetSearch or TweetUser starts from the first page.
+func (c *TwitterScraper) DeleteCheckpoint(ctx context.Context, kind CheckpointKind, query string) error {
+	if c.checkpoints == nil {
+		return nil
+	}
+	return c.checkpoints.Delete(ctx, c.checkpointKey(kind, query))
+}
+
+// Resume continues the TweetSearch or TweetUser call saved under the query
+// or screen name from its last cursor, until the call reaches its original
+// maxTweets. A finished scrape yields a closed channel, a missing one
+// store.ErrNotFound.
+func (c *TwitterScraper) Resume(ctx context.Context, kind CheckpointKind, query string) <-chan *TweetResult {
+	cp, err := c.LoadCheckpoint(ctx, kind, query)
+	if err != nil {
+		return errorChannel(err)
+	}
+
+	// the current endpoints, query IDs may have been rotated since
+	endpoint, apiType, fn := c.endpoints.Search+"?", APIStandart, parseTweets(parseTimeline)
+	switch cp.Kind {
+	case CheckpointSearch:
+	case CheckpointUser:
+		endpoint, apiType, fn = c.endpoints.graphqlURL(OpUserTweets)+"?", APIGraphql, parseTimelineV2
+	default:
+		return errorChannel(errors.New("sns: unknown checkpoint kind " + string(cp.Kind)))
+	}
+
+	channel := make(chan *TweetResult)
+	if cp.Done || cp.Count >= cp.MaxTweets {
+		close(channel)
+		return channel
+	}
+
+	pagination := url.Values{}
+	for k, v := range cp.Params {
+		pagination[k] = v
+	}
+	go c.iteratorApiData(ctx, endpoint, cp.Params, pagination, cp.Cursor, cp.MaxTweets, apiType, channel, fn, cp)
+	return channel
+}
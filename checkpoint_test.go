@@ -0,0 +1,64 @@
+package sns_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/store"
+	"github.com/hinha/go-social-network/twittertest"
+)
+
+func TestCheckpointPerSettings(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{
+		Users:    fakeUsers,
+		Tweets:   fakeTweets(10),
+		PageSize: 2,
+	})
+	defer srv.Close()
+	st := store.NewFileStore(filepath.Join(t.TempDir(), "store.json"))
+	january := sns.DateRange{Since: "2021-01-01", Until: "2021-01-31"}
+	saved := newFakeScraper(t, srv, sns.Config{Date: january}, sns.WithCheckpointStore(st))
+
+	ctx := context.Background()
+	for r := range saved.TweetSearch(ctx, "golang", 4) {
+		if r.Error != nil {
+			t.Fatal(r.Error)
+		}
+	}
+	if _, err := st.Get(ctx, sns.StoreKeyCheckpoint+"search:keyword:latest:id:2021-01-01..2021-01-31:golang"); err != nil {
+		t.Fatalf("checkpoint key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		conf  sns.Config
+		found bool
+	}{
+		{"same settings", sns.Config{Date: january}, true},
+		{"other window", sns.Config{Date: sns.DateRange{Since: "2021-02-01", Until: "2021-02-28"}}, false},
+		{"top tab", sns.Config{Date: january, SearchTab: sns.TabTop}, false},
+		{"english", sns.Config{Date: january, Lang: sns.LangEn}, false},
+		{"hashtag mode", sns.Config{Date: january, SearchMode: sns.SearchHashtag}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper := newFakeScraper(t, srv, tt.conf, sns.WithCheckpointStore(st))
+			cp, err := scraper.LoadCheckpoint(ctx, sns.CheckpointSearch, "golang")
+			if !tt.found {
+				if !errors.Is(err, store.ErrNotFound) {
+					t.Fatalf("checkpoint %+v, %v, want store.ErrNotFound", cp, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cp.Count != 4 {
+				t.Fatalf("checkpoint count %d, want 4", cp.Count)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package sns
+
+import (
+	"sort"
+
+	"github.com/hinha/go-social-network/entities"
+)
+
+// Engagement counters of a single tweet.
+type Engagement struct {
+	Likes    int `json:"likes"`
+	Retweets int `json:"retweets"`
+	Replies  int `json:"replies"`
+	Quotes   int `json:"quotes"`
+}
+
+// Total sum of all counters.
+func (e Engagement) Total() int {
+	return e.Likes + e.Retweets + e.Replies + e.Quotes
+}
+
+// ReplyNode is one tweet of a reply tree.
+type ReplyNode struct {
+	Id int
+	// Post is nil when the tweet was referenced by a reply but not scraped,
+	// e.g. a deleted parent or one outside the scraped window.
+	Post       *entities.TwitterPost
+	Parent     *ReplyNode
+	Children   []*ReplyNode
+	Depth      int
+	Engagement Engagement
+}
+
+// Missing reports whether the node is a placeholder for an unscraped tweet.
+func (n *ReplyNode) Missing() bool {
+	return n.Post == nil
+}
+
+// Walk visits the node and its descendants depth first, children in ID
+// (i.e. chronological) order. Returning false stops the descent into a node.
+func (n *ReplyNode) Walk(fn func(node *ReplyNode) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// Conversation is the reply tree of a single ConversationId.
+type Conversation struct {
+	Id   int
+	Root *ReplyNode
+	// RootAuthor is empty when the root tweet was not scraped.
+	RootAuthor entities.TwitterUser
+	// Detached holds subtrees whose chain up to the root is broken by a
+	// missing tweet. Their depth is relative to their own top node.
+	Detached []*ReplyNode
+	// Size number of scraped tweets, placeholders excluded.
+	Size int
+	// Depth longest reply chain below the root.
+	Depth int
+	// MaxBranching highest number of direct replies of a single tweet.
+	MaxBranching int
+	// AvgBranching mean number of direct replies of the tweets having replies.
+	AvgBranching float64
+}
+
+// Node returns the node with the given tweet ID, nil when unknown.
+func (c *Conversation) Node(id int) *ReplyNode {
+	var found *ReplyNode
+	visit := func(node *ReplyNode) bool {
+		if node.Id == id {
+			found = node
+		}
+		return found == nil
+	}
+	c.Root.Walk(visit)
+	for _, node := range c.Detached {
+		if found != nil {
+			break
+		}
+		node.Walk(visit)
+	}
+	return found
+}
+
+// ConversationBuilder assembles posts coming from TweetSearch, TweetUser or
+// TweetThread into reply trees keyed on ConversationId and InReplyToTweetId.
+// It is not safe for concurrent use.
+type ConversationBuilder struct {
+	posts map[int]*entities.TwitterPost
+}
+
+func NewConversationBuilder() *ConversationBuilder {
+	return &ConversationBuilder{posts: make(map[int]*entities.TwitterPost)}
+}
+
+// Add records a post. Retweets are recorded as the retweeted tweet, a post
+// added twice keeps its latest version.
+func (b *ConversationBuilder) Add(post *entities.TwitterPost) {
+	if post == nil {
+		return
+	}
+	if post.RetweetedTweet != nil {
+		post = post.RetweetedTweet
+	}
+	b.posts[post.Id] = post
+}
+
+// AddResults consumes results until the channel is closed and records every
+// post. It returns the first error seen, results after it are still consumed.
+func (b *ConversationBuilder) AddResults(results <-chan *TweetResult) error {
+	var first error
+	for result := range results {
+		if result.Error != nil && first == nil {
+			first = result.Error
+		}
+		b.Add(result.TwitterPost)
+	}
+	return first
+}
+
+// Conversation builds the tree of a single conversation, nil when no post of
+// it was added.
+func (b *ConversationBuilder) Conversation(id int) *Conversation {
+	for _, conv := range b.Build() {
+		if conv.Id == id {
+			return conv
+		}
+	}
+	return nil
+}
+
+// Build assembles all recorded posts into conversations ordered by ID. Every
+// call returns a fresh snapshot.
+func (b *ConversationBuilder) Build() []*Conversation {
+	groups := make(map[int]map[int]*ReplyNode)
+	for _, post := range b.posts {
+		convID := post.ConversationId
+		if convID == 0 {
+			convID = post.Id
+		}
+		if groups[convID] == nil {
+			groups[convID] = make(map[int]*ReplyNode)
+		}
+		groups[convID][post.Id] = &ReplyNode{
+			Id:   post.Id,
+			Post: post,
+			Engagement: Engagement{
+				Likes:    post.LikeCount,
+				Retweets: post.RetweetCount,
+				Replies:  post.ReplyCount,
+				Quotes:   post.QuoteCount,
+			},
+		}
+	}
+
+	conversations := make([]*Conversation, 0, len(groups))
+	for convID, nodes := range groups {
+		conversations = append(conversations, buildConversation(convID, nodes))
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].Id < conversations[j].Id
+	})
+	return conversations
+}
+
+func buildConversation(convID int, nodes map[int]*ReplyNode) *Conversation {
+	conv := &Conversation{Id: convID, Size: len(nodes)}
+
+	// link every scraped tweet to its parent, creating placeholders for
+	// parents that were not scraped
+	scraped := make([]*ReplyNode, 0, len(nodes))
+	for _, node := range nodes {
+		scraped = append(scraped, node)
+	}
+	for _, node := range scraped {
+		parentID := node.Post.InReplyToTweetId
+		if node.Id == convID || parentID == 0 {
+			continue
+		}
+		parent, ok := nodes[parentID]
+		if !ok {
+			parent = &ReplyNode{Id: parentID}
+			nodes[parentID] = parent
+		}
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+
+	root, ok := nodes[convID]
+	if !ok {
+		root = &ReplyNode{Id: convID}
+		nodes[convID] = root
+	}
+	conv.Root = root
+	if root.Post != nil {
+		conv.RootAuthor = root.Post.User
+	}
+
+	var parents, replies int
+	for _, node := range nodes {
+		sort.Slice(node.Children, func(i, j int) bool {
+			return node.Children[i].Id < node.Children[j].Id
+		})
+		if n := len(node.Children); n > 0 {
+			parents++
+			replies += n
+			if n > conv.MaxBranching {
+				conv.MaxBranching = n
+			}
+		}
+		if node.Parent == nil && node != root {
+			conv.Detached = append(conv.Detached, node)
+		}
+	}
+	if parents > 0 {
+		conv.AvgBranching = float64(replies) / float64(parents)
+	}
+	sort.Slice(conv.Detached, func(i, j int) bool {
+		return conv.Detached[i].Id < conv.Detached[j].Id
+	})
+
+	setDepth(root, 0, &conv.Depth)
+	var detachedDepth int
+	for _, node := range conv.Detached {
+		setDepth(node, 0, &detachedDepth)
+	}
+	return conv
+}
+
+func setDepth(node *ReplyNode, depth int, max *int) {
+	node.Depth = depth
+	if depth > *max {
+		*max = depth
+	}
+	for _, child := range node.Children {
+		setDepth(child, depth+1, max)
+	}
+}
@@ -0,0 +1,169 @@
+package sns_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/entities"
+)
+
+// reply is tweet id of conversation 1 replying to parent, 0 for the root.
+func reply(id, parent int) *entities.TwitterPost {
+	return &entities.TwitterPost{
+		Id:               id,
+		ConversationId:   1,
+		InReplyToTweetId: parent,
+		User:             entities.TwitterUser{Id: 7, Username: "alice"},
+	}
+}
+
+// depths returns the depth of every node below top by ID.
+func depths(top *sns.ReplyNode) map[int]int {
+	found := make(map[int]int)
+	top.Walk(func(node *sns.ReplyNode) bool {
+		found[node.Id] = node.Depth
+		return true
+	})
+	return found
+}
+
+func TestConversationBuilder(t *testing.T) {
+	tests := []struct {
+		name  string
+		posts []*entities.TwitterPost
+		// depths of the nodes reachable from the root, by ID
+		depths       map[int]int
+		depth        int
+		size         int
+		maxBranching int
+		avgBranching float64
+		// detached top nodes and the depths below each
+		detached map[int]map[int]int
+	}{
+		{
+			name:         "chain",
+			posts:        []*entities.TwitterPost{reply(1, 0), reply(2, 1), reply(3, 2), reply(4, 3)},
+			depths:       map[int]int{1: 0, 2: 1, 3: 2, 4: 3},
+			depth:        3,
+			size:         4,
+			maxBranching: 1,
+			avgBranching: 1,
+		},
+		{
+			name:         "branching",
+			posts:        []*entities.TwitterPost{reply(1, 0), reply(2, 1), reply(3, 1), reply(4, 1), reply(5, 3)},
+			depths:       map[int]int{1: 0, 2: 1, 3: 1, 4: 1, 5: 2},
+			depth:        2,
+			size:         5,
+			maxBranching: 3,
+			avgBranching: 2,
+		},
+		{
+			name:         "missing parent detaches its subtree",
+			posts:        []*entities.TwitterPost{reply(1, 0), reply(2, 1), reply(4, 3), reply(5, 4)},
+			depths:       map[int]int{1: 0, 2: 1},
+			depth:        1,
+			size:         4,
+			maxBranching: 1,
+			avgBranching: 1,
+			detached:     map[int]map[int]int{3: {3: 0, 4: 1, 5: 2}},
+		},
+		{
+			name:         "missing root",
+			posts:        []*entities.TwitterPost{reply(2, 1), reply(3, 1), reply(4, 2)},
+			depths:       map[int]int{1: 0, 2: 1, 3: 1, 4: 2},
+			depth:        2,
+			size:         3,
+			maxBranching: 2,
+			avgBranching: 1.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := sns.NewConversationBuilder()
+			for _, post := range tt.posts {
+				b.Add(post)
+			}
+			conv := b.Conversation(1)
+			if conv == nil {
+				t.Fatal("no conversation 1")
+			}
+			if got := depths(conv.Root); !reflect.DeepEqual(got, tt.depths) {
+				t.Errorf("depths %v, want %v", got, tt.depths)
+			}
+			if conv.Depth != tt.depth || conv.Size != tt.size {
+				t.Errorf("depth %d size %d, want %d and %d", conv.Depth, conv.Size, tt.depth, tt.size)
+			}
+			if conv.MaxBranching != tt.maxBranching || conv.AvgBranching != tt.avgBranching {
+				t.Errorf("branching max %d avg %v, want %d and %v", conv.MaxBranching, conv.AvgBranching, tt.maxBranching, tt.avgBranching)
+			}
+
+			detached := make(map[int]map[int]int)
+			for _, top := range conv.Detached {
+				if !top.Missing() || top.Parent != nil {
+					t.Errorf("detached node %d is not a placeholder top", top.Id)
+				}
+				detached[top.Id] = depths(top)
+			}
+			if (len(detached) > 0 || len(tt.detached) > 0) && !reflect.DeepEqual(detached, tt.detached) {
+				t.Errorf("detached %v, want %v", detached, tt.detached)
+			}
+		})
+	}
+}
+
+func TestConversationBuilderRoot(t *testing.T) {
+	b := sns.NewConversationBuilder()
+	b.Add(reply(2, 1))
+	conv := b.Conversation(1)
+	if !conv.Root.Missing() || conv.RootAuthor.Username != "" {
+		t.Fatalf("root %+v author %+v, want a placeholder", conv.Root, conv.RootAuthor)
+	}
+
+	b.Add(reply(1, 0))
+	conv = b.Conversation(1)
+	if conv.Root.Missing() || conv.RootAuthor.Username != "alice" {
+		t.Fatalf("root %+v author %+v, want the scraped root", conv.Root, conv.RootAuthor)
+	}
+	if node := conv.Node(2); node == nil || node.Parent != conv.Root {
+		t.Fatalf("node 2 %+v, want a reply of the root", node)
+	}
+	if conv.Node(99) != nil {
+		t.Fatal("unknown node found")
+	}
+}
+
+func TestConversationBuilderAdd(t *testing.T) {
+	b := sns.NewConversationBuilder()
+
+	// retweets count as the retweeted tweet, the latest version wins
+	b.Add(&entities.TwitterPost{Id: 50, RetweetedTweet: reply(2, 1)})
+	updated := reply(2, 1)
+	updated.LikeCount = 5
+	b.Add(updated)
+
+	// a tweet without a conversation is its own
+	b.Add(&entities.TwitterPost{Id: 9})
+
+	results := make(chan *sns.TweetResult, 3)
+	results <- &sns.TweetResult{TwitterPost: reply(1, 0)}
+	results <- &sns.TweetResult{Error: sns.ErrRateLimited}
+	results <- &sns.TweetResult{TwitterPost: reply(3, 2)}
+	close(results)
+	if err := b.AddResults(results); !errors.Is(err, sns.ErrRateLimited) {
+		t.Fatalf("error %v, want the first error", err)
+	}
+
+	convs := b.Build()
+	if len(convs) != 2 || convs[0].Id != 1 || convs[1].Id != 9 {
+		t.Fatalf("conversations %+v, want 1 and 9", convs)
+	}
+	if conv := convs[0]; conv.Size != 3 || conv.Node(50) != nil || conv.Node(3) == nil {
+		t.Fatalf("conversation %+v, want the retweeted tweet and the results after the error", conv)
+	}
+	if likes := convs[0].Node(2).Engagement.Likes; likes != 5 {
+		t.Fatalf("%d likes, want those of the latest version", likes)
+	}
+}
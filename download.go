@@ -0,0 +1,485 @@
+package sns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hinha/go-social-network/entities"
+)
+
+// ManifestFile is the manifest name written next to the media of a tweet.
+const ManifestFile = "manifest.json"
+
+var regexPathUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// MediaDownloader saves the media of tweets under Dir as
+// <user>/<tweet id>/<index>.<ext>, index counted from 1 in the order of
+// TwitterPost.Media, and describes them in a ManifestFile in the same
+// directory. Videos and gifs are saved as their highest bitrate mp4, photos
+// in their original size.
+//
+// Files are written to a .part file first; an interrupted download is
+// resumed with a Range request and files already saved with the checksum of
+// their manifest are not fetched again.
+type MediaDownloader struct {
+	Dir string
+	// Client fetches the media, http.DefaultClient when nil.
+	Client *http.Client
+	// Workers concurrent downloads, 4 by default.
+	Workers int
+
+	locks pathLocks
+}
+
+// NewMediaDownloader creates a downloader saving under dir.
+func NewMediaDownloader(dir string) *MediaDownloader {
+	return &MediaDownloader{Dir: dir, Workers: 4}
+}
+
+// MediaManifest describes the saved media of a tweet.
+type MediaManifest struct {
+	TweetId int                 `json:"tweet_id"`
+	Url     string              `json:"url"`
+	User    string              `json:"user"`
+	Date    *time.Time          `json:"date"`
+	Files   []MediaManifestFile `json:"files"`
+}
+
+// MediaManifestFile is a media of a MediaManifest. Path is relative to the
+// manifest and empty when the download failed.
+type MediaManifestFile struct {
+	Index    int                `json:"index"`
+	Type     entities.MediaType `json:"type"`
+	MediaKey string             `json:"media_key"`
+	Url      string             `json:"url"`
+	Path     string             `json:"path"`
+	Size     int64              `json:"size"`
+	SHA256   string             `json:"sha256"`
+	AltText  string             `json:"alt_text,omitempty"`
+	Width    int                `json:"width,omitempty"`
+	Height   int                `json:"height,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// DownloadResult is a media file of a tweet, the failed manifest write of a
+// tweet, or the error of a tweet result passed through.
+type DownloadResult struct {
+	Tweet *entities.TwitterPost
+	// Index of the media in Tweet.Media, from 1. It is 0 for the manifest.
+	Index int
+	Url   string
+	// Path of the saved file.
+	Path   string
+	Size   int64
+	SHA256 string
+	// Cached is set when the file was already saved with the checksum of
+	// the manifest.
+	Cached bool
+	Error  error
+}
+
+type mediaJob struct {
+	tweet *tweetDownload
+	index int
+	media entities.TwitterMedia
+}
+
+// tweetDownload collects the files of a tweet until its manifest can be
+// written.
+type tweetDownload struct {
+	mu      sync.Mutex
+	dir     string
+	post    *entities.TwitterPost
+	files   []MediaManifestFile
+	pending int
+	// saved checksums of the files of a previous manifest, by path
+	saved map[string]string
+}
+
+// Download saves the media of the tweets and returns one result per media.
+// The errors of tweet results are passed through, the media of a tweet with
+// an error are saved all the same. The channel is closed once tweets is
+// drained and every download is done, or ctx is canceled.
+func (d *MediaDownloader) Download(ctx context.Context, tweets <-chan *TweetResult) <-chan *DownloadResult {
+	results := make(chan *DownloadResult)
+	jobs := make(chan mediaJob)
+
+	send := func(r *DownloadResult) bool {
+		select {
+		case results <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	workers := d.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				r := d.fetch(ctx, job)
+				err := job.tweet.done(job.index, job.media, r, &d.locks)
+				send(r)
+				if err != nil {
+					send(&DownloadResult{
+						Tweet: job.tweet.post,
+						Path:  filepath.Join(job.tweet.dir, ManifestFile),
+						Error: err,
+					})
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for tweet := range tweets {
+			if tweet.Error != nil {
+				// a tweet may come with the error of a part of it, its
+				// media are still saved
+				if !send(&DownloadResult{Tweet: tweet.TwitterPost, Error: tweet.Error}) {
+					return
+				}
+			}
+			if tweet.TwitterPost == nil || len(tweet.Media) == 0 {
+				continue
+			}
+			dir := filepath.Join(d.Dir, userDir(tweet.User), strconv.Itoa(tweet.Id))
+			t := &tweetDownload{
+				dir:     dir,
+				post:    tweet.TwitterPost,
+				files:   make([]MediaManifestFile, len(tweet.Media)),
+				pending: len(tweet.Media),
+				saved:   savedFiles(dir),
+			}
+			for i, media := range tweet.Media {
+				select {
+				case jobs <- mediaJob{tweet: t, index: i + 1, media: media}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func userDir(user entities.TwitterUser) string {
+	if user.Username != "" {
+		return regexPathUnsafe.ReplaceAllString(user.Username, "_")
+	}
+	return strconv.Itoa(user.Id)
+}
+
+// fetch saves a media file.
+func (d *MediaDownloader) fetch(ctx context.Context, job mediaJob) *DownloadResult {
+	r := &DownloadResult{Tweet: job.tweet.post, Index: job.index}
+	if !job.media.Available {
+		r.Error = newError(ErrMediaUnavailable, "download", "media %s: %s", job.media.MediaKey, job.media.UnavailableReason)
+		return r
+	}
+	u, ext := bestVariant(job.media)
+	if u == "" {
+		r.Error = newError(ErrMediaUnavailable, "download", "media %s: no downloadable variant", job.media.MediaKey)
+		return r
+	}
+	r.Url = u
+	r.Path = filepath.Join(job.tweet.dir, strconv.Itoa(job.index)+ext)
+	// a tweet seen twice must not download into the same .part file at once
+	defer d.locks.lock(r.Path)()
+
+	sum := job.tweet.saved[filepath.Base(r.Path)]
+	if sum != "" {
+		if size, hash, err := checksum(r.Path); err == nil && hash == sum {
+			r.Size, r.SHA256, r.Cached = size, hash, true
+			return r
+		}
+	}
+	if err := d.get(ctx, u, r.Path, sum); err != nil {
+		r.Error = err
+		return r
+	}
+	r.Size, r.SHA256, r.Error = checksum(r.Path)
+	return r
+}
+
+// savedFiles returns the checksums of the files saved by the manifest in
+// dir, by path.
+func savedFiles(dir string) map[string]string {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil
+	}
+	var manifest MediaManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+	saved := make(map[string]string, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if file.Path != "" && file.SHA256 != "" {
+			saved[file.Path] = file.SHA256
+		}
+	}
+	return saved
+}
+
+// pathLocks serializes the work on a file path.
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+type pathLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks path and returns its unlock.
+func (p *pathLocks) lock(path string) func() {
+	p.mu.Lock()
+	if p.locks == nil {
+		p.locks = make(map[string]*pathLock)
+	}
+	l := p.locks[path]
+	if l == nil {
+		l = &pathLock{}
+		p.locks[path] = l
+	}
+	l.refs++
+	p.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(p.locks, path)
+		}
+	}
+}
+
+// bestVariant returns the URL and file extension to save media as: the
+// highest bitrate mp4 of videos and gifs, the original size of photos.
+func bestVariant(media entities.TwitterMedia) (string, string) {
+	switch media.Type {
+	case entities.MediaVideo, entities.MediaGif:
+		var best *entities.TwitterVideoVariant
+		for i, variant := range media.Variants {
+			if variant.ContentType == "video/mp4" && (best == nil || variant.BitRate > best.BitRate) {
+				best = &media.Variants[i]
+			}
+		}
+		if best == nil {
+			return "", ""
+		}
+		return best.Url, ".mp4"
+	default:
+		u := mediaUrl(media.Url, "orig")
+		if u == "" {
+			u = media.Url
+		}
+		parsed, err := url.Parse(u)
+		if err != nil || u == "" {
+			return "", ""
+		}
+		if format := parsed.Query().Get("format"); format != "" {
+			return u, "." + format
+		}
+		return u, path.Ext(parsed.Path)
+	}
+}
+
+// get downloads u to file through file.part, resuming a previous attempt.
+// sum is the checksum of the file in the manifest, empty when unknown.
+func (d *MediaDownloader) get(ctx context.Context, u, file, sum string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	part := file + ".part"
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	var total int64 = -1
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flag |= os.O_APPEND
+		total = contentRangeTotal(resp.Header.Get("Content-Range"))
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the part file may be complete, or longer than the file and corrupt
+		if partComplete(part, offset, sum, contentRangeTotal(resp.Header.Get("Content-Range"))) {
+			return os.Rename(part, file)
+		}
+		if err := os.Remove(part); err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return d.get(ctx, u, file, sum)
+	case resp.StatusCode == http.StatusOK:
+		flag |= os.O_TRUNC
+		total = resp.ContentLength
+	default:
+		return statusError("download", resp)
+	}
+
+	f, err := os.OpenFile(part, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	if total >= 0 {
+		info, err := os.Stat(part)
+		if err != nil {
+			return err
+		}
+		if info.Size() != total {
+			return newError(ErrBadStatus, "download", "%s: got %d of %d bytes", u, info.Size(), total)
+		}
+	}
+	return os.Rename(part, file)
+}
+
+// partComplete reports whether the part file of size bytes is the whole
+// file: it matches the checksum of the manifest or, without one, the total
+// length of the 416 response.
+func partComplete(part string, size int64, sum string, total int64) bool {
+	if sum != "" {
+		_, hash, err := checksum(part)
+		return err == nil && hash == sum
+	}
+	return total >= 0 && size == total
+}
+
+// contentRangeTotal returns the complete length of a Content-Range header,
+// -1 when unknown.
+func contentRangeTotal(contentRange string) int64 {
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return -1
+	}
+	total, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return total
+}
+
+// checksum returns the size and SHA-256 of file.
+func checksum(file string) (int64, string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// done records the file of media index and writes the manifest once every
+// media of the tweet is done.
+func (t *tweetDownload) done(index int, media entities.TwitterMedia, r *DownloadResult, locks *pathLocks) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file := MediaManifestFile{
+		Index:    index,
+		Type:     media.Type,
+		MediaKey: media.MediaKey,
+		Url:      r.Url,
+		Size:     r.Size,
+		SHA256:   r.SHA256,
+		AltText:  media.AltText,
+		Width:    media.Width,
+		Height:   media.Height,
+	}
+	if r.Error != nil {
+		file.Error = r.Error.Error()
+	} else {
+		file.Path = filepath.Base(r.Path)
+	}
+	t.files[index-1] = file
+	t.pending--
+	if t.pending > 0 {
+		return nil
+	}
+
+	manifest := MediaManifest{
+		TweetId: t.post.Id,
+		Url:     t.post.Url,
+		User:    t.post.User.Username,
+		Date:    t.post.Date,
+		Files:   t.files,
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+	name := filepath.Join(t.dir, ManifestFile)
+	defer locks.lock(name)()
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	return nil
+}
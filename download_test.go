@@ -0,0 +1,268 @@
+package sns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hinha/go-social-network/entities"
+)
+
+var videoContent = bytes.Repeat([]byte("0123456789"), 100)
+
+// mediaServer serves videoContent with Range support. The first request of
+// /short.mp4 is cut in the middle.
+type mediaServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+	shortCut bool
+}
+
+func newMediaServer(t *testing.T) *mediaServer {
+	s := &mediaServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path+" "+r.Header.Get("Range"))
+		cut := r.URL.Path == "/short.mp4" && !s.shortCut
+		s.shortCut = s.shortCut || cut
+		s.mu.Unlock()
+
+		if cut {
+			w.Header().Set("Content-Length", "1000")
+			w.Write(videoContent[:400])
+			return
+		}
+		http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(videoContent))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *mediaServer) requested() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func videoTweet(s *mediaServer, path string) *TweetResult {
+	return &TweetResult{TwitterPost: &entities.TwitterPost{
+		Id:   1,
+		User: entities.TwitterUser{Username: "alice"},
+		Media: []entities.TwitterMedia{{
+			Type:      entities.MediaVideo,
+			MediaKey:  "7_1",
+			Available: true,
+			Variants: []entities.TwitterVideoVariant{
+				{ContentType: "video/mp4", Url: s.URL + "/low.mp4", BitRate: 256000},
+				{ContentType: "application/x-mpegURL", Url: s.URL + "/playlist.m3u8"},
+				{ContentType: "video/mp4", Url: s.URL + path, BitRate: 2176000},
+			},
+		}},
+	}}
+}
+
+func download(t *testing.T, d *MediaDownloader, tweets ...*TweetResult) []*DownloadResult {
+	t.Helper()
+	ch := make(chan *TweetResult, len(tweets))
+	for _, tweet := range tweets {
+		ch <- tweet
+	}
+	close(ch)
+	var results []*DownloadResult
+	for r := range d.Download(context.Background(), ch) {
+		results = append(results, r)
+	}
+	return results
+}
+
+func checkVideo(t *testing.T, dir string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "alice", "1", "1.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, videoContent) {
+		t.Fatalf("saved %d bytes, want the %d of the video", len(data), len(videoContent))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "alice", "1", "1.mp4.part")); !os.IsNotExist(err) {
+		t.Fatalf("part file left behind: %v", err)
+	}
+}
+
+func TestMediaDownloader(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		// part is the content of the .part file of a previous attempt
+		part     []byte
+		requests []string
+	}{
+		{
+			name:     "best variant",
+			path:     "/high.mp4",
+			requests: []string{"/high.mp4 "},
+		},
+		{
+			name:     "range resume",
+			path:     "/high.mp4",
+			part:     videoContent[:300],
+			requests: []string{"/high.mp4 bytes=300-"},
+		},
+		{
+			name:     "416 on a complete part",
+			path:     "/high.mp4",
+			part:     videoContent,
+			requests: []string{"/high.mp4 bytes=1000-"},
+		},
+		{
+			name:     "416 on a corrupt part",
+			path:     "/high.mp4",
+			part:     append(append([]byte(nil), videoContent...), "junk"...),
+			requests: []string{"/high.mp4 bytes=1004-", "/high.mp4 "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newMediaServer(t)
+			dir := t.TempDir()
+			if tt.part != nil {
+				part := filepath.Join(dir, "alice", "1", "1.mp4.part")
+				if err := os.MkdirAll(filepath.Dir(part), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(part, tt.part, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			results := download(t, NewMediaDownloader(dir), videoTweet(srv, tt.path))
+			if len(results) != 1 || results[0].Error != nil {
+				t.Fatalf("results %+v, want one download", results)
+			}
+			if r := results[0]; r.Url != srv.URL+tt.path || r.Size != int64(len(videoContent)) || r.Cached {
+				t.Fatalf("result %+v", r)
+			}
+			if got := srv.requested(); strings.Join(got, ",") != strings.Join(tt.requests, ",") {
+				t.Fatalf("requests %q, want %q", got, tt.requests)
+			}
+			checkVideo(t, dir)
+		})
+	}
+}
+
+func TestMediaDownloaderShortRead(t *testing.T) {
+	srv := newMediaServer(t)
+	dir := t.TempDir()
+	d := NewMediaDownloader(dir)
+
+	results := download(t, d, videoTweet(srv, "/short.mp4"))
+	if len(results) != 1 || results[0].Error == nil {
+		t.Fatalf("results %+v, want the short read to fail", results)
+	}
+	results = download(t, d, videoTweet(srv, "/short.mp4"))
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("results %+v, want the download resumed", results)
+	}
+	want := []string{"/short.mp4 ", "/short.mp4 bytes=400-"}
+	if got := srv.requested(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("requests %q, want %q", got, want)
+	}
+	checkVideo(t, dir)
+}
+
+func TestMediaDownloaderCached(t *testing.T) {
+	srv := newMediaServer(t)
+	dir := t.TempDir()
+	d := NewMediaDownloader(dir)
+	if results := download(t, d, videoTweet(srv, "/high.mp4")); len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("results %+v", results)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "alice", "1", ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest MediaManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Path != "1.mp4" || manifest.Files[0].SHA256 == "" {
+		t.Fatalf("manifest %+v", manifest)
+	}
+
+	results := download(t, d, videoTweet(srv, "/high.mp4"))
+	if len(results) != 1 || !results[0].Cached || results[0].SHA256 != manifest.Files[0].SHA256 {
+		t.Fatalf("results %+v, want the saved file", results)
+	}
+	if got := len(srv.requested()); got != 1 {
+		t.Fatalf("%d requests, want the cached file not fetched again", got)
+	}
+
+	// a file that does not match its manifest is fetched again
+	if err := os.WriteFile(filepath.Join(dir, "alice", "1", "1.mp4"), []byte("corrupt"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results = download(t, d, videoTweet(srv, "/high.mp4"))
+	if len(results) != 1 || results[0].Cached || results[0].Error != nil {
+		t.Fatalf("results %+v, want the file downloaded again", results)
+	}
+	checkVideo(t, dir)
+}
+
+func TestMediaDownloaderDuplicates(t *testing.T) {
+	srv := newMediaServer(t)
+	dir := t.TempDir()
+
+	tweets := make([]*TweetResult, 8)
+	for i := range tweets {
+		tweets[i] = videoTweet(srv, "/high.mp4")
+	}
+	results := download(t, NewMediaDownloader(dir), tweets...)
+	if len(results) != len(tweets) {
+		t.Fatalf("%d results, want %d", len(results), len(tweets))
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			t.Fatal(r.Error)
+		}
+	}
+	checkVideo(t, dir)
+}
+
+func TestMediaDownloaderTweetErrors(t *testing.T) {
+	srv := newMediaServer(t)
+	dir := t.TempDir()
+
+	card := newError(ErrUnsupportedCard, "parse", "card poll9choice")
+	tweet := videoTweet(srv, "/high.mp4")
+	tweet.Error = card
+	results := download(t, NewMediaDownloader(dir), tweet, &TweetResult{Error: ErrRateLimited})
+
+	var errs []error
+	var saved int
+	for _, r := range results {
+		switch {
+		case r.Error != nil:
+			errs = append(errs, r.Error)
+		case r.Index == 1:
+			saved++
+		}
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], ErrUnsupportedCard) || !errors.Is(errs[1], ErrRateLimited) {
+		t.Fatalf("errors %v, want both tweet errors passed through", errs)
+	}
+	if saved != 1 {
+		t.Fatalf("results %+v, want the media of the tweet with an error saved", results)
+	}
+	checkVideo(t, dir)
+}
@@ -0,0 +1,197 @@
+package sns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GraphQL operations used by the scraper, the keys of Endpoints.Operations.
+const (
+	OpUserByScreenName = "UserByScreenName"
+	OpUserByRestID     = "UserByRestId"
+	OpUserTweets       = "UserTweetsAndReplies"
+	OpTweetDetail      = "TweetDetail"
+)
+
+// GraphQLOperation is a GraphQL operation of the web client. Twitter rotates
+// the query IDs, so they can be replaced without a new release.
+type GraphQLOperation struct {
+	QueryID string `json:"query_id,omitempty" yaml:"query_id,omitempty"`
+	// Variables sent with every request of the operation, next to the
+	// request specific ones such as userId or cursor.
+	Variables map[string]interface{} `json:"variables,omitempty" yaml:"variables,omitempty"`
+	// Features sent as the features parameter, omitted when empty.
+	Features map[string]interface{} `json:"features,omitempty" yaml:"features,omitempty"`
+}
+
+// Endpoints are the URLs the scraper talks to. Set Config.Endpoints to point
+// a scraper at a mirror or a local fake server, or load them with
+// LoadEndpoints to hot-fix rotated query IDs. Empty fields, missing
+// operations and missing variables or features keep their default.
+type Endpoints struct {
+	// Web is the site the guest token pages are loaded from.
+	Web    string `json:"web,omitempty" yaml:"web,omitempty"`
+	Token  string `json:"token,omitempty" yaml:"token,omitempty"`
+	Search string `json:"search,omitempty" yaml:"search,omitempty"`
+	// GraphQL is the base URL of the operations, each one is sent to
+	// <GraphQL>/<query id>/<operation>.
+	GraphQL    string                      `json:"graphql,omitempty" yaml:"graphql,omitempty"`
+	Operations map[string]GraphQLOperation `json:"operations,omitempty" yaml:"operations,omitempty"`
+}
+
+// DefaultEndpoints the public twitter endpoints.
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		Web:     "https://twitter.com",
+		Token:   TwitterAPIToken,
+		Search:  TwitterAPISearch,
+		GraphQL: "https://twitter.com/i/api/graphql",
+		Operations: map[string]GraphQLOperation{
+			OpUserByScreenName: {
+				QueryID: "7mjxD3-C6BxitPMVQ6w0-Q",
+				Variables: map[string]interface{}{
+					"withSafetyModeUserFields":   true,
+					"withSuperFollowsUserFields": true,
+				},
+			},
+			OpUserByRestID: {
+				QueryID: "GazOglcBvgLigl3ywt6b3Q",
+				Variables: map[string]interface{}{
+					"withSafetyModeUserFields":   true,
+					"withSuperFollowsUserFields": true,
+				},
+			},
+			OpUserTweets: {
+				QueryID: "BSKxQ9_IaCoVyIvQHQROIQ",
+				Variables: map[string]interface{}{
+					"count":                       100,
+					"includePromotedContent":      true,
+					"withCommunity":               true,
+					"withSuperFollowsUserFields":  true,
+					"withDownvotePerspective":     false,
+					"withReactionsMetadata":       false,
+					"withReactionsPerspective":    false,
+					"withSuperFollowsTweetFields": true,
+					"withVoice":                   true,
+					"withV2Timeline":              false,
+				},
+			},
+			OpTweetDetail: {
+				QueryID: "Z3RLjlDHMdnBdbfKy0MdPg",
+				Variables: map[string]interface{}{
+					"with_rux_injections":                    false,
+					"includePromotedContent":                 true,
+					"withCommunity":                          true,
+					"withQuickPromoteEligibilityTweetFields": true,
+					"withBirdwatchNotes":                     false,
+					"withSuperFollowsUserFields":             true,
+					"withDownvotePerspective":                false,
+					"withReactionsMetadata":                  false,
+					"withReactionsPerspective":               false,
+					"withSuperFollowsTweetFields":            true,
+					"withVoice":                              true,
+					"withV2Timeline":                         false,
+				},
+			},
+		},
+	}
+}
+
+// LoadEndpoints reads endpoints from a YAML (.yaml, .yml) or JSON file, e.g.
+//
+//	operations:
+//	  UserTweetsAndReplies:
+//	    query_id: 8IS8MaO-2EN6GZZZb8jF0g
+//	    features:
+//	      responsive_web_graphql_timeline_navigation_enabled: true
+func LoadEndpoints(file string) (*Endpoints, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var e Endpoints
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &e)
+	default:
+		err = json.Unmarshal(data, &e)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("endpoints %s: %w", file, err)
+	}
+	return &e, nil
+}
+
+func (e *Endpoints) orDefault() Endpoints {
+	endpoints := DefaultEndpoints()
+	if e == nil {
+		return endpoints
+	}
+	for _, f := range []struct {
+		value string
+		field *string
+	}{
+		{e.Web, &endpoints.Web},
+		{e.Token, &endpoints.Token},
+		{e.Search, &endpoints.Search},
+		{e.GraphQL, &endpoints.GraphQL},
+	} {
+		if f.value != "" {
+			*f.field = strings.TrimSuffix(f.value, "/")
+		}
+	}
+
+	for name, op := range e.Operations {
+		merged := endpoints.Operations[name]
+		if op.QueryID != "" {
+			merged.QueryID = op.QueryID
+		}
+		merged.Variables = mergeValues(merged.Variables, op.Variables)
+		merged.Features = mergeValues(merged.Features, op.Features)
+		endpoints.Operations[name] = merged
+	}
+	return endpoints
+}
+
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// graphqlURL returns the URL of the operation name.
+func (e Endpoints) graphqlURL(name string) string {
+	return e.GraphQL + "/" + e.Operations[name].QueryID + "/" + name
+}
+
+// graphqlParams encodes the variables and features of the operation of
+// endpoint, vars overriding the operation variables.
+func (e Endpoints) graphqlParams(endpoint string, vars map[string]interface{}) string {
+	op := e.Operations[path.Base(strings.TrimSuffix(endpoint, "?"))]
+	merged := mergeValues(op.Variables, vars)
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+	variables, _ := json.Marshal(merged)
+	params := "variables=" + url.QueryEscape(string(variables))
+	if len(op.Features) > 0 {
+		features, _ := json.Marshal(op.Features)
+		params += "&features=" + url.QueryEscape(string(features))
+	}
+	return params
+}
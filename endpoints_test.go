@@ -0,0 +1,145 @@
+package sns
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "endpoints.yaml",
+			content: `
+graphql: https://mirror.example/graphql/
+operations:
+  UserTweetsAndReplies:
+    query_id: 8IS8MaO-2EN6GZZZb8jF0g
+    variables:
+      count: 40
+    features:
+      responsive_web_graphql_timeline_navigation_enabled: true
+`,
+		},
+		{
+			name: "yml",
+			file: "endpoints.YML",
+			content: `
+graphql: https://mirror.example/graphql
+operations:
+  UserTweetsAndReplies: {query_id: 8IS8MaO-2EN6GZZZb8jF0g, variables: {count: 40}, features: {responsive_web_graphql_timeline_navigation_enabled: true}}
+`,
+		},
+		{
+			name: "json",
+			file: "endpoints.json",
+			content: `{
+				"graphql": "https://mirror.example/graphql",
+				"operations": {"UserTweetsAndReplies": {
+					"query_id": "8IS8MaO-2EN6GZZZb8jF0g",
+					"variables": {"count": 40},
+					"features": {"responsive_web_graphql_timeline_navigation_enabled": true}
+				}}
+			}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadEndpoints(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := loaded.orDefault()
+
+			if got, want := e.graphqlURL(OpUserTweets), "https://mirror.example/graphql/8IS8MaO-2EN6GZZZb8jF0g/UserTweetsAndReplies"; got != want {
+				t.Fatalf("url %s, want %s", got, want)
+			}
+			op := e.Operations[OpUserTweets]
+			if count, _ := json.Marshal(op.Variables["count"]); string(count) != "40" {
+				t.Fatalf("count %v, want the loaded one", op.Variables["count"])
+			}
+			if op.Variables["withVoice"] != true {
+				t.Fatalf("variables %v, want the defaults kept", op.Variables)
+			}
+			if op.Features["responsive_web_graphql_timeline_navigation_enabled"] != true {
+				t.Fatalf("features %v", op.Features)
+			}
+		})
+	}
+}
+
+func TestLoadEndpointsErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadEndpoints(filepath.Join(dir, "missing.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("error %v, want the missing file", err)
+	}
+
+	file := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(file, []byte(`{"operations": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadEndpoints(file); err == nil || !strings.Contains(err.Error(), file) {
+		t.Fatalf("error %v, want it to name the file", err)
+	}
+}
+
+func TestEndpointsOrDefault(t *testing.T) {
+	var unset *Endpoints
+	if got := unset.orDefault(); !reflect.DeepEqual(got, DefaultEndpoints()) {
+		t.Fatalf("nil endpoints %+v, want the defaults", got)
+	}
+
+	e := (&Endpoints{
+		Web:    "http://127.0.0.1:8080/",
+		Search: "http://127.0.0.1:8080/2/search/adaptive.json",
+		Operations: map[string]GraphQLOperation{
+			// an operation override keeps the default query ID
+			OpTweetDetail: {Variables: map[string]interface{}{"withVoice": false}},
+			"Unknown":     {QueryID: "abc"},
+		},
+	}).orDefault()
+
+	defaults := DefaultEndpoints()
+	if e.Web != "http://127.0.0.1:8080" || e.Token != defaults.Token || e.GraphQL != defaults.GraphQL {
+		t.Fatalf("endpoints %+v", e)
+	}
+	detail := e.Operations[OpTweetDetail]
+	if detail.QueryID != defaults.Operations[OpTweetDetail].QueryID {
+		t.Fatalf("query id %s, want the default", detail.QueryID)
+	}
+	if detail.Variables["withVoice"] != false || detail.Variables["withCommunity"] != true {
+		t.Fatalf("variables %v, want withVoice overridden and the rest kept", detail.Variables)
+	}
+	if e.Operations["Unknown"].QueryID != "abc" {
+		t.Fatalf("operations %v, want the unknown operation added", e.Operations)
+	}
+
+	// request variables override those of the operation
+	params, err := url.ParseQuery(e.graphqlParams(e.graphqlURL(OpTweetDetail)+"?", map[string]interface{}{"focalTweetId": "1", "withVoice": true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var variables map[string]interface{}
+	if err := json.Unmarshal([]byte(params.Get("variables")), &variables); err != nil {
+		t.Fatal(err)
+	}
+	if variables["focalTweetId"] != "1" || variables["withVoice"] != true || variables["withCommunity"] != true {
+		t.Fatalf("variables %v", variables)
+	}
+	if params.Has("features") {
+		t.Fatalf("params %v, want no features for an operation without any", params)
+	}
+}
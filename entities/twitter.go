@@ -31,19 +31,189 @@ type TwitterVideoVariant struct {
 	BitRate     int    `json:"bit_rate"`
 }
 
+// MediaType is the kind of a TwitterMedia.
+type MediaType string
+
+const (
+	MediaPhoto MediaType = "photo"
+	MediaVideo MediaType = "video"
+	MediaGif   MediaType = "animated_gif"
+)
+
+// TwitterMediaSize is a size variant of the media image, smallest first and
+// the original last.
+type TwitterMediaSize struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Resize is fit or crop.
+	Resize string `json:"resize"`
+}
+
+// TwitterMedia is a photo, video or gif of a tweet, in the order they are
+// shown.
+type TwitterMedia struct {
+	Type     MediaType `json:"type"`
+	Id       int       `json:"id"`
+	MediaKey string    `json:"media_key"`
+	// Url is the image of a photo, the thumbnail of a video or gif.
+	Url        string             `json:"url"`
+	PreviewUrl string             `json:"preview_url,omitempty"`
+	FullUrl    string             `json:"full_url,omitempty"`
+	Width      int                `json:"width"`
+	Height     int                `json:"height"`
+	AltText    string             `json:"alt_text"`
+	Sizes      []TwitterMediaSize `json:"sizes"`
+	// Variants, Duration in seconds and Views of videos and gifs.
+	Variants []TwitterVideoVariant `json:"variants,omitempty"`
+	Duration float64               `json:"duration,omitempty"`
+	Views    int                   `json:"views,omitempty"`
+	// Available is false when the media was removed, e.g. after a copyright
+	// claim, UnavailableReason tells why.
+	Available         bool   `json:"available"`
+	UnavailableReason string `json:"unavailable_reason,omitempty"`
+}
+
+// TwitterPoll is the poll of a tweet.
+type TwitterPoll struct {
+	Options    []TwitterPollOption `json:"options"`
+	TotalVotes int                 `json:"total_votes"`
+	EndDate    *time.Time          `json:"end_date"`
+	// LastUpdated is when the counts were last updated.
+	LastUpdated     *time.Time `json:"last_updated"`
+	DurationMinutes int        `json:"duration_minutes"`
+	// Final is set once the poll is over and the counts are final.
+	Final bool `json:"final"`
+	// ImageUrl of image polls.
+	ImageUrl string `json:"image_url,omitempty"`
+}
+
+// TwitterPollOption is a choice of a TwitterPoll, in the order shown.
+type TwitterPollOption struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
 type TwitterCard struct {
-	SummaryCard map[string]interface{} `json:"summary_card"`
-	AppCard     map[string]interface{} `json:"app_card"`
-	PlayerCard  map[string]interface{} `json:"player_card"`
+	SummaryCard *TwitterSummaryCard    `json:"summary_card,omitempty"`
+	AppCard     *TwitterAppCard        `json:"app_card,omitempty"`
+	PlayerCard  *TwitterPlayerCard     `json:"player_card,omitempty"`
+	Newsletter  *TwitterNewsletterCard `json:"newsletter,omitempty"`
+	Amplify     *TwitterAmplifyCard    `json:"amplify,omitempty"`
+	AppPlayer   *TwitterAppPlayerCard  `json:"app_player,omitempty"`
+	Broadcast   *TwitterBroadcastCard  `json:"broadcast,omitempty"`
+	// Generic holds the cards without a typed variant.
+	Generic *TwitterGenericCard `json:"generic,omitempty"`
+}
+
+// TwitterNewsletterCard is a newsletter_publication or newsletter_issue card.
+type TwitterNewsletterCard struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Url         string `json:"url"`
+	ImageUrl    string `json:"image_url"`
+	// Issue fields of newsletter_issue cards.
+	IssueTitle       string `json:"issue_title,omitempty"`
+	IssueDescription string `json:"issue_description,omitempty"`
+	IssueNumber      int    `json:"issue_number,omitempty"`
+}
+
+// TwitterAmplifyCard is a video of an amplify card.
+type TwitterAmplifyCard struct {
+	Url          string                `json:"url"`
+	ThumbnailUrl string                `json:"thumbnail_url"`
+	Duration     float64               `json:"duration"`
+	Variants     []TwitterVideoVariant `json:"variants"`
+}
+
+// TwitterAppPlayerCard is an appplayer card, an app promoted with a video.
+type TwitterAppPlayerCard struct {
+	Title        string                `json:"title"`
+	AppCategory  string                `json:"app_category"`
+	AppRating    float64               `json:"app_rating"`
+	AppRatings   int                   `json:"app_ratings"`
+	Url          string                `json:"url"`
+	ThumbnailUrl string                `json:"thumbnail_url"`
+	Variants     []TwitterVideoVariant `json:"variants"`
+}
+
+// TwitterBroadcastCard is a live broadcast or periscope broadcast card.
+type TwitterBroadcastCard struct {
+	Id    string `json:"id"`
+	Url   string `json:"url"`
+	Title string `json:"title"`
+	// State is e.g. RUNNING or ENDED.
+	State        string       `json:"state"`
+	ThumbnailUrl string       `json:"thumbnail_url"`
+	Broadcaster  *TwitterUser `json:"broadcaster,omitempty"`
+}
+
+// TwitterGenericCard keeps the parsed binding values of a card by key.
+type TwitterGenericCard struct {
+	Name          string                 `json:"name"`
+	BindingValues map[string]interface{} `json:"binding_values"`
+}
+
+// TwitterImageColor is a color of the palette of a card image.
+type TwitterImageColor struct {
+	Red        int     `json:"red"`
+	Green      int     `json:"green"`
+	Blue       int     `json:"blue"`
+	Percentage float64 `json:"percentage"`
+}
+
+// TwitterSummaryCard is a summary or summary_large_image link preview.
+type TwitterSummaryCard struct {
+	Title        string `json:"title"`
+	Url          string `json:"url"`
+	Domain       string `json:"domain"`
+	Description  string `json:"description"`
+	ThumbnailUrl string `json:"thumbnail_url"`
+	// ThumbnailColors is the palette of the thumbnail, main color first.
+	ThumbnailColors []TwitterImageColor `json:"thumbnail_colors,omitempty"`
+	// LargeImage is set for summary_large_image cards.
+	LargeImage  bool         `json:"large_image"`
+	SiteUser    *TwitterUser `json:"site_user,omitempty"`
+	CreatorUser *TwitterUser `json:"creator_user,omitempty"`
+}
+
+// TwitterAppCard is an app or direct_store_link_app card.
+type TwitterAppCard struct {
+	Title           string              `json:"title"`
+	Url             string              `json:"url"`
+	Description     string              `json:"description"`
+	ThumbnailUrl    string              `json:"thumbnail_url"`
+	ThumbnailColors []TwitterImageColor `json:"thumbnail_colors,omitempty"`
+	AppName         string              `json:"app_name"`
+	AppCategory     string              `json:"app_category"`
+	// Store IDs of the app, empty when it is not in that store.
+	IPhoneId     string       `json:"iphone_id,omitempty"`
+	IPadId       string       `json:"ipad_id,omitempty"`
+	GooglePlayId string       `json:"googleplay_id,omitempty"`
+	Rating       float64      `json:"rating"`
+	Ratings      int          `json:"ratings"`
+	Price        float64      `json:"price"`
+	Currency     string       `json:"currency"`
+	Free         bool         `json:"free"`
+	SiteUser     *TwitterUser `json:"site_user,omitempty"`
 }
 
-type twitterSummaryCard struct {
-	Title        string      `json:"title"`
-	Url          string      `json:"url"`
-	Description  string      `json:"description"`
-	ThumbnailUrl string      `json:"thumbnail_url"`
-	SiteUser     TwitterUser `json:"site_user"`
-	CreatorUser  TwitterUser `json:"creator_user"`
+// TwitterPlayerCard is a player card, media embedded from another site.
+type TwitterPlayerCard struct {
+	Title           string              `json:"title"`
+	Url             string              `json:"url"`
+	Description     string              `json:"description"`
+	ThumbnailUrl    string              `json:"thumbnail_url"`
+	ThumbnailColors []TwitterImageColor `json:"thumbnail_colors,omitempty"`
+	// PlayerUrl is the page embedded in the tweet, StreamUrl the raw
+	// media when the site provides one.
+	PlayerUrl         string       `json:"player_url"`
+	StreamUrl         string       `json:"stream_url,omitempty"`
+	StreamContentType string       `json:"stream_content_type,omitempty"`
+	Width             int          `json:"width"`
+	Height            int          `json:"height"`
+	SiteUser          *TwitterUser `json:"site_user,omitempty"`
 }
 
 type TwitterPost struct {
@@ -62,23 +232,8 @@ type TwitterPost struct {
 	SourceLabel     string            `json:"source_label"`
 	Content         string            `json:"content"`
 	Links           []TwitterTextLink `json:"links"`
-	Media           struct {
-		Photo struct {
-			PreviewUrl string `json:"preview_url"`
-			FullUrl    string `json:"full_url"`
-		} `json:"photo"`
-		Video struct {
-			ThumbnailUrl string                `json:"thumbnail_url"`
-			Variants     []TwitterVideoVariant `json:"variants"`
-			Duration     float64               `json:"duration"`
-			Views        int                   `json:"views"`
-		}
-		Gif struct {
-			ThumbnailUrl string                `json:"thumbnail_url"`
-			Variants     []TwitterVideoVariant `json:"variants"`
-		} `json:"gif"`
-	} `json:"media"`
-	RetweetedTweet *TwitterPost `json:"retweeted_tweet"`
+	Media           []TwitterMedia    `json:"media"`
+	RetweetedTweet  *TwitterPost      `json:"retweeted_tweet"`
 
 	QuotedTweet          *TwitterPost        `json:"quoted_tweet"`
 	QuotedTweetRef       *TweetRef           `json:"quoted_tweet_ref"`
@@ -91,6 +246,7 @@ type TwitterPost struct {
 	Hashtags             []string            `json:"hashtags"`
 	CashTags             []string            `json:"cash_tags"`
 	Card                 TwitterCard         `json:"card"`
+	Poll                 *TwitterPoll        `json:"poll,omitempty"`
 	User                 TwitterUser         `json:"user"`
 }
 
@@ -133,4 +289,11 @@ type TwitterUser struct {
 		LongDescription string `json:"long_description"`
 	} `json:"label"`
 	Url string `json:"url"`
+	// ExpandedUrl is Url unshortened.
+	ExpandedUrl string `json:"expanded_url,omitempty"`
+	// DescriptionUrls are the unshortened links of the description.
+	DescriptionUrls     []string `json:"description_urls,omitempty"`
+	PinnedTweetIds      []int    `json:"pinned_tweet_ids,omitempty"`
+	HasNftAvatar        bool     `json:"has_nft_avatar"`
+	WithheldInCountries []string `json:"withheld_in_countries,omitempty"`
 }
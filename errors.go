@@ -0,0 +1,135 @@
+package sns
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error classes returned through TweetResult.Error. Every error produced by the
+// scraper wraps one of them, so callers can decide per class whether to retry,
+// skip the entry or abort with errors.Is, and inspect the details with
+// errors.As on *ScrapeError.
+var (
+	// ErrRateLimited the API answered 429 Too Many Requests.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrBlocked the API refused the request (403) or the guest token was rejected.
+	ErrBlocked = errors.New("blocked")
+	// ErrUserUnavailable the requested account is suspended, deactivated or does not exist.
+	ErrUserUnavailable = errors.New("user unavailable")
+	// ErrUnsupportedCard the tweet carries a card the parser does not understand.
+	// Cards of an unknown name are kept in TwitterCard.Generic instead.
+	ErrUnsupportedCard = errors.New("unsupported card")
+	// ErrSchemaChanged the response does not have the shape the parser expects.
+	ErrSchemaChanged = errors.New("unexpected response schema")
+	// ErrBadStatus the API answered with an unexpected non-200 status code.
+	ErrBadStatus = errors.New("unexpected status code")
+	// ErrNoProxy every proxy of the pool is quarantined.
+	ErrNoProxy = errors.New("no healthy proxy")
+	// ErrInvalidQuery the search query built by QueryBuilder is invalid.
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrMediaUnavailable the media of a tweet was withheld or has no
+	// downloadable variant.
+	ErrMediaUnavailable = errors.New("media unavailable")
+	// ErrShardTruncated a shard of a sharded search had more than
+	// ShardPolicy.MaxPerShard tweets but could not be split any more, the
+	// tweets past the limit were not fetched.
+	ErrShardTruncated = errors.New("shard truncated")
+)
+
+// ScrapeError describes a failure of a single scraper operation.
+type ScrapeError struct {
+	// Kind is one of the Err* classes above.
+	Kind error
+	// Op is the operation that failed, e.g. "search", "user", "token" or "card".
+	Op string
+	// Msg carries extra context such as an entry or card name.
+	Msg string
+	// Err is the underlying cause, may be nil.
+	Err error
+}
+
+func (e *ScrapeError) Error() string {
+	s := "sns: " + e.Op + ": " + e.Kind.Error()
+	if e.Msg != "" {
+		s += ": " + e.Msg
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Unwrap returns the underlying cause.
+func (e *ScrapeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the class of this error.
+func (e *ScrapeError) Is(target error) bool {
+	return e.Kind == target
+}
+
+// UnavailableReason is why an account cannot be shown.
+type UnavailableReason string
+
+const (
+	// ReasonNotFound the account does not exist or was deactivated.
+	ReasonNotFound UnavailableReason = "NotFound"
+	// ReasonSuspended the account was suspended.
+	ReasonSuspended UnavailableReason = "Suspended"
+)
+
+// UserUnavailableError is the error of a profile lookup for an account that
+// cannot be shown. It wraps ErrUserUnavailable.
+type UserUnavailableError struct {
+	// User is the screen name or ID that was looked up.
+	User string
+	// Reason is one of the Reason* constants, or the reason given by the
+	// API as is for other ones.
+	Reason UnavailableReason
+	// Message is the text the web client shows instead of the profile.
+	Message string
+}
+
+func (e *UserUnavailableError) Error() string {
+	s := "sns: user: " + ErrUserUnavailable.Error() + ": " + e.User + ": " + string(e.Reason)
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
+
+// Is reports whether target is ErrUserUnavailable.
+func (e *UserUnavailableError) Is(target error) bool {
+	return target == ErrUserUnavailable
+}
+
+func newError(kind error, op string, format string, args ...interface{}) error {
+	return &ScrapeError{Kind: kind, Op: op, Msg: fmt.Sprintf(format, args...)}
+}
+
+func wrapError(kind error, op string, err error) error {
+	return &ScrapeError{Kind: kind, Op: op, Err: err}
+}
+
+// statusError maps the response status to an error class, nil on 200.
+func statusError(op string, r *http.Response) error {
+	switch {
+	case r.StatusCode == http.StatusTooManyRequests:
+		return newError(ErrRateLimited, op, "status %d", r.StatusCode)
+	case r.StatusCode == http.StatusForbidden || r.StatusCode == http.StatusUnauthorized:
+		return newError(ErrBlocked, op, "status %d", r.StatusCode)
+	case r.StatusCode != http.StatusOK:
+		return newError(ErrBadStatus, op, "status %d", r.StatusCode)
+	}
+	return nil
+}
+
+// recoverSchema turns a panic raised while walking untyped JSON into an
+// ErrSchemaChanged error, so one odd entry never takes down the process.
+func recoverSchema(op, entryID string, err *error) {
+	if r := recover(); r != nil {
+		*err = newError(ErrSchemaChanged, op, "entry %s: %v", entryID, r)
+	}
+}
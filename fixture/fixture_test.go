@@ -0,0 +1,155 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const secretToken = "1577777777777777777"
+
+// tokenServer answers like the guest token endpoints, with a secret token in
+// headers and bodies.
+func tokenServer(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/1.1/guest/activate.json":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"guest_token": "`+secretToken+`"}`)
+		case "/search":
+			http.SetCookie(w, &http.Cookie{Name: "gt", Value: secretToken, Path: "/"})
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<script>document.cookie = decodeURIComponent("gt=`+secretToken+`; Max-Age=10800");</script>`)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"page": `+strconv.Itoa(int(n))+`}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func get(t *testing.T, client *http.Client, method, u, body string) (int, string) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-bearer")
+	req.Header.Set("x-guest-token", secretToken)
+	req.Header.Set("Cookie", "gt="+secretToken)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// compact drops the indentation JSON bodies get in the fixture files.
+func compact(body string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(body)); err != nil {
+		return body
+	}
+	return buf.String()
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv, hits := tokenServer(t)
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: NewRecorder(dir, nil)}
+
+	exchanges := []struct {
+		method, path, body string
+	}{
+		{http.MethodGet, "/search?q=golang&f=live", ""},
+		{http.MethodPost, "/1.1/guest/activate.json", `{"guest_token":"` + secretToken + `"}`},
+		{http.MethodGet, "/2/search/adaptive.json?q=golang", ""},
+		{http.MethodGet, "/2/search/adaptive.json?q=golang", ""},
+	}
+	recorded := make([]string, len(exchanges))
+	for i, e := range exchanges {
+		_, recorded[i] = get(t, recorder, e.method, srv.URL+e.path, e.body)
+	}
+	if !strings.Contains(recorded[0], secretToken) {
+		t.Fatalf("the recorder changed the live response %q", recorded[0])
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("%d fixtures %v, want 3", len(files), files)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{secretToken, "secret-bearer"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s holds %q:\n%s", filepath.Base(file), secret, data)
+			}
+		}
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: replayer}
+	before := atomic.LoadInt32(hits)
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		want         string
+	}{
+		{"html page", http.MethodGet, "/search?f=live&q=golang", "", strings.ReplaceAll(recorded[0], secretToken, ScrubbedToken)},
+		{"token body", http.MethodPost, "/1.1/guest/activate.json", `{"guest_token":"1588888888888888888"}`, `{"guest_token":"` + ScrubbedToken + `"}`},
+		{"first page", http.MethodGet, "/2/search/adaptive.json?q=golang", "", recorded[2]},
+		{"second page", http.MethodGet, "/2/search/adaptive.json?q=golang", "", recorded[3]},
+		{"last page repeated", http.MethodGet, "/2/search/adaptive.json?q=golang", "", recorded[3]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := get(t, client, tt.method, srv.URL+tt.path, tt.body)
+			if status != http.StatusOK || compact(body) != compact(tt.want) {
+				t.Fatalf("replayed %d %q, want %q", status, body, tt.want)
+			}
+		})
+	}
+	if got := atomic.LoadInt32(hits); got != before {
+		t.Fatalf("the replayer sent %d requests to the server", got-before)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/2/search/adaptive.json?q=rust", nil)
+	if _, err := replayer.RoundTrip(req); !errors.Is(err, ErrNoFixture) {
+		t.Fatalf("unknown request: %v, want ErrNoFixture", err)
+	}
+	if fixtures := replayer.Fixtures("adaptive.json"); len(fixtures) != 1 || len(fixtures[0].Responses) != 2 {
+		t.Fatalf("fixtures %+v, want one with 2 responses", fixtures)
+	}
+}
@@ -2,6 +2,9 @@ module github.com/hinha/go-social-network
 
 go 1.18
 
-require github.com/sirupsen/logrus v1.8.1
+require (
+	github.com/sirupsen/logrus v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
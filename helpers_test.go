@@ -0,0 +1,79 @@
+package sns_test
+
+import (
+	"testing"
+	"time"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/twittertest"
+)
+
+var fakeUsers = []twittertest.User{{ID: 1, ScreenName: "alice", Name: "Alice"}}
+
+// fastRetry retries right away so that the faults do not slow the tests.
+var fastRetry = &sns.RetryPolicy{
+	MaxAttempts:   3,
+	BaseDelay:     time.Millisecond,
+	RetryStatuses: []int{429},
+}
+
+// newFakeScraper returns a scraper of conf pointed at srv.
+func newFakeScraper(t *testing.T, srv *twittertest.Server, conf sns.Config, opts ...sns.Option) *sns.TwitterScraper {
+	t.Helper()
+	conf.Endpoints = srv.Endpoints()
+	if conf.Date == (sns.DateRange{}) {
+		conf.Date = sns.DateRange{Since: "2021-01-01", Until: "2021-01-31"}
+	}
+	scraper, err := sns.NewTwitterScraper(&conf, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scraper
+}
+
+// fakeTweets returns n tweets of user 1, newest first, an hour apart on
+// 2021-01-01.
+func fakeTweets(n int) []twittertest.Tweet {
+	tweets := make([]twittertest.Tweet, n)
+	for i := range tweets {
+		tweets[i] = twittertest.Tweet{
+			ID:        1000 + n - i,
+			UserID:    1,
+			Text:      "tweet",
+			CreatedAt: time.Date(2021, 1, 1, n-i, 0, 0, 0, time.UTC),
+		}
+	}
+	return tweets
+}
+
+// collect drains tweets and returns the IDs of the posts and the errors.
+func collect(t *testing.T, tweets <-chan *sns.TweetResult) (ids []int, errs []error) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case r, ok := <-tweets:
+			if !ok {
+				return ids, errs
+			}
+			if r.TwitterPost != nil {
+				ids = append(ids, r.Id)
+			}
+			if r.Error != nil {
+				errs = append(errs, r.Error)
+			}
+		case <-timeout:
+			t.Fatal("results channel not closed")
+		}
+	}
+}
+
+func tweetIds(tweets []twittertest.Tweet) []int {
+	ids := make([]int, 0, len(tweets))
+	for _, tweet := range tweets {
+		if !tweet.Tombstone {
+			ids = append(ids, tweet.ID)
+		}
+	}
+	return ids
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -70,6 +71,8 @@ func Formatter(out io.Writer) *log.Logger {
 type logger struct {
 	Writer
 	Config
+	// mu guards fields, the logger is shared by concurrent scrapes
+	mu     *sync.RWMutex
 	fields log.Fields
 }
 
@@ -77,6 +80,7 @@ func New(writer Writer, config Config) Interface {
 	return &logger{
 		Writer: writer,
 		Config: config,
+		mu:     &sync.RWMutex{},
 		fields: make(log.Fields),
 	}
 }
@@ -88,12 +92,21 @@ func (l *logger) LogMode(level LogLevel) Interface {
 	return &newlogger
 }
 
-func fields(l log.Fields, begin time.Time) log.Fields {
-	l["duration"] = fmt.Sprintf("%.3fms", float64(time.Since(begin).Nanoseconds())/1e6)
-	return l
+// withDuration returns a copy of the fields with the time elapsed since begin.
+func (l *logger) withDuration(begin time.Time) log.Fields {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	fields := make(log.Fields, len(l.fields)+1)
+	for key, value := range l.fields {
+		fields[key] = value
+	}
+	fields["duration"] = fmt.Sprintf("%.3fms", float64(time.Since(begin).Nanoseconds())/1e6)
+	return fields
 }
 
 func (l *logger) Init(fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	for key, value := range fields {
 		l.fields[key] = value
 	}
@@ -102,21 +115,21 @@ func (l *logger) Init(fields map[string]interface{}) {
 func (l *logger) Info(begin time.Time, args ...interface{}) {
 	if l.LogLevel >= Info {
 		l.SetLevel(log.InfoLevel)
-		l.WithFields(fields(l.fields, begin)).Info(args...)
+		l.WithFields(l.withDuration(begin)).Info(args...)
 	}
 }
 
 func (l *logger) Error(begin time.Time, args ...interface{}) {
 	if l.LogLevel >= Error {
 		l.SetLevel(log.ErrorLevel)
-		l.WithFields(fields(l.fields, begin)).Debug(args...)
+		l.WithFields(l.withDuration(begin)).Debug(args...)
 	}
 }
 
 func (l *logger) Debug(begin time.Time, args ...interface{}) {
 	if l.LogLevel >= Debug {
 		l.SetLevel(log.DebugLevel)
-		l.WithFields(fields(l.fields, begin)).Debug(args...)
+		l.WithFields(l.withDuration(begin)).Debug(args...)
 	}
 }
 
@@ -127,20 +140,22 @@ func (l *logger) Trace(ctx context.Context, begin time.Time, fc func() (string,
 	elapsed := time.Since(begin)
 
 	_, statusCode := fc()
-	l.fields["duration"] = fmt.Sprintf("%.3fms", float64(elapsed.Nanoseconds())/1e6)
-	l.fields["status_code"] = statusCode
+	fields := l.withDuration(begin)
+	fields["status_code"] = statusCode
 	switch {
 	case err != nil && l.LogLevel >= Error:
-		l.WithFields(l.fields).Error(err)
+		l.WithFields(fields).Error(err)
 	case elapsed > l.SlowThreshold && l.SlowThreshold != 0 && l.LogLevel >= Warn:
 		showLog := fmt.Sprintf("SLOW REQUEST >= %v", l.SlowThreshold)
-		l.WithFields(l.fields).Warn(showLog)
+		l.WithFields(fields).Warn(showLog)
 	case l.LogLevel == Info:
-		l.WithFields(l.fields).Info()
+		l.WithFields(fields).Info()
 	}
 }
 
 func (k *logger) SetField(key string, value interface{}) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
 	k.fields[key] = value
 }
 
@@ -0,0 +1,153 @@
+package sns
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hinha/go-social-network/store"
+	"github.com/hinha/go-social-network/utils"
+)
+
+const defaultRequestTimeout = 10 * time.Second
+
+// Option configures how a TwitterScraper talks to the network.
+type Option func(*options)
+
+type options struct {
+	client      *http.Client
+	transport   http.RoundTripper
+	timeout     time.Duration
+	tlsConfig   *tls.Config
+	proxies     *ProxyPool
+	tokenPool   *utils.TokenPool
+	store       store.Store
+	limiter     *RateLimiter
+	checkpoints store.Store
+}
+
+func newOptions(opts []Option) options {
+	o := options{timeout: defaultRequestTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithHTTPClient sends every request with a copy of client instead of a
+// private one. The copy gets a cookie jar when client has none, client itself
+// is left untouched and its Timeout applies on top of the per-request timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithTransport sets the RoundTripper of the http client, e.g. to route
+// through an egress proxy, add tracing middleware or hit a local fake server.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = rt
+	}
+}
+
+// WithRequestTimeout bounds every single request attempt, 10s by default.
+// Zero disables the timeout.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithTLSConfig sets the TLS settings of the transport. It needs the
+// default transport or an *http.Transport, NewTwitterScraper fails when
+// WithTransport or WithHTTPClient set any other RoundTripper.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithProxyPool sends every request through a proxy picked from pool. Like
+// WithTLSConfig it needs the default transport or an *http.Transport.
+func WithProxyPool(pool *ProxyPool) Option {
+	return func(o *options) {
+		o.proxies = pool
+	}
+}
+
+// WithTokenPool shares a guest token pool between scrapers instead of each
+// scraper fetching its own token.
+func WithTokenPool(pool *utils.TokenPool) Option {
+	return func(o *options) {
+		o.tokenPool = pool
+	}
+}
+
+// WithStore loads guest tokens and cookies from st on creation and flushes
+// them back whenever a guest token is fetched or retired, see Flush.
+// Use store.NewFileStore for a JSON file.
+func WithStore(st store.Store) Option {
+	return func(o *options) {
+		o.store = st
+	}
+}
+
+// WithRateLimiter shares a rate limiter between scrapers. Every scraper has
+// its own by default.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithCheckpointStore saves a Checkpoint after every page of TweetSearch
+// and TweetUser to st, see Resume. The store set by WithStore is used when
+// this option is not given.
+func WithCheckpointStore(st store.Store) Option {
+	return func(o *options) {
+		o.checkpoints = st
+	}
+}
+
+// roundTripper returns the transport to use, nil for http.DefaultTransport.
+// The proxy pool and TLS settings can only be applied to an *http.Transport.
+func (o options) roundTripper(current http.RoundTripper) (http.RoundTripper, error) {
+	rt := current
+	if o.transport != nil {
+		rt = o.transport
+	}
+	if o.tlsConfig == nil && o.proxies == nil {
+		return rt, nil
+	}
+
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("sns: WithProxyPool and WithTLSConfig need an *http.Transport, got %T", rt)
+	}
+	t = t.Clone()
+	if o.tlsConfig != nil {
+		t.TLSClientConfig = o.tlsConfig
+	}
+	if o.proxies != nil {
+		t.Proxy = proxyFunc(t.Proxy)
+	}
+	return t, nil
+}
+
+// cancelBody releases the per-request timeout once the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
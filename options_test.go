@@ -0,0 +1,23 @@
+package sns
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/hinha/go-social-network/store"
+)
+
+func TestWithHTTPClientCopiesClient(t *testing.T) {
+	client := &http.Client{}
+	scraper, err := NewTwitterScraper(testConfig(), WithHTTPClient(client), WithStore(store.NewFileStore(filepath.Join(t.TempDir(), "store.json"))), WithTransport(&http.Transport{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Jar != nil || client.Transport != nil {
+		t.Fatalf("client was modified: jar %v, transport %v", client.Jar, client.Transport)
+	}
+	if got := scraper.scraper.GetClient(); got == client || got.Jar == nil || got.Transport == nil {
+		t.Fatalf("scraper client %+v, want a copy with jar and transport", got)
+	}
+}
@@ -0,0 +1,172 @@
+package sns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hinha/go-social-network/store"
+)
+
+// Keys used in the store set by WithStore.
+const (
+	StoreKeyGuestTokens = "twitter:guest_tokens"
+	StoreKeyGuestToken  = "twitter:guest_token"
+	StoreKeyCookies     = "twitter:cookies"
+)
+
+type storedCookie struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+// persistentJar wraps a cookie jar and remembers the persistent cookies it
+// was given, which net/http/cookiejar cannot enumerate. Session cookies are
+// not kept.
+type persistentJar struct {
+	http.CookieJar
+	mu      sync.Mutex
+	cookies map[string]storedCookie
+}
+
+func newPersistentJar(jar http.CookieJar) *persistentJar {
+	return &persistentJar{CookieJar: jar, cookies: make(map[string]storedCookie)}
+}
+
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.CookieJar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, cookie := range cookies {
+		domain := cookie.Domain
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		key := domain + ";" + cookie.Path + ";" + cookie.Name
+
+		expires := cookie.Expires
+		if cookie.MaxAge > 0 {
+			expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if cookie.MaxAge < 0 || expires.IsZero() || !now.Before(expires) {
+			delete(j.cookies, key)
+			continue
+		}
+		j.cookies[key] = storedCookie{
+			URL:      u.Scheme + "://" + u.Host + "/",
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			Expires:  expires,
+		}
+	}
+}
+
+func (j *persistentJar) load(ctx context.Context, st store.Store) error {
+	data, err := st.Get(ctx, StoreKeyCookies)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var cookies []storedCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, c := range cookies {
+		if !now.Before(c.Expires) {
+			continue
+		}
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			continue
+		}
+		j.SetCookies(u, []*http.Cookie{{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			Expires:  c.Expires,
+		}})
+	}
+	return nil
+}
+
+func (j *persistentJar) flush(ctx context.Context, st store.Store) error {
+	j.mu.Lock()
+	now := time.Now()
+	var expires time.Time
+	cookies := make([]storedCookie, 0, len(j.cookies))
+	for key, c := range j.cookies {
+		if !now.Before(c.Expires) {
+			delete(j.cookies, key)
+			continue
+		}
+		if c.Expires.After(expires) {
+			expires = c.Expires
+		}
+		cookies = append(cookies, c)
+	}
+	j.mu.Unlock()
+
+	if len(cookies) == 0 {
+		return st.Delete(ctx, StoreKeyCookies)
+	}
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+	return st.Set(ctx, StoreKeyCookies, data, expires.Sub(now))
+}
+
+// loadStore restores guest tokens and cookies saved by a previous run.
+func (c *TwitterScraper) loadStore(ctx context.Context) error {
+	if err := c.tokenPool.Load(ctx, c.store, StoreKeyGuestTokens); err != nil {
+		return err
+	}
+	if err := c.tokenManager.Load(ctx, c.store, StoreKeyGuestToken); err != nil {
+		return err
+	}
+	if c.scraper.jar != nil {
+		return c.scraper.jar.load(ctx, c.store)
+	}
+	return nil
+}
+
+// Flush saves the guest tokens and cookies to the store set by WithStore, so
+// the next run does not have to fetch a new guest token. It is called
+// automatically whenever a guest token was fetched or retired.
+func (c *TwitterScraper) Flush(ctx context.Context) error {
+	if c.store == nil {
+		return nil
+	}
+	if err := c.tokenPool.Flush(ctx, c.store, StoreKeyGuestTokens); err != nil {
+		return err
+	}
+	if err := c.tokenManager.Flush(ctx, c.store, StoreKeyGuestToken); err != nil {
+		return err
+	}
+	if c.scraper.jar != nil {
+		return c.scraper.jar.flush(ctx, c.store)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package sns_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/store"
+	"github.com/hinha/go-social-network/twittertest"
+	"github.com/hinha/go-social-network/utils"
+)
+
+// countingStore counts the writes of every key.
+type countingStore struct {
+	store.Store
+	mu   sync.Mutex
+	sets map[string]int
+}
+
+func newCountingStore(t *testing.T) *countingStore {
+	return &countingStore{Store: store.NewFileStore(filepath.Join(t.TempDir(), "store.json")), sets: make(map[string]int)}
+}
+
+func (s *countingStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	s.sets[key]++
+	s.mu.Unlock()
+	return s.Store.Set(ctx, key, value, ttl)
+}
+
+func (s *countingStore) count(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sets[key]
+}
+
+func TestStoreFlushedOnNewTokens(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: fakeTweets(20), PageSize: 2})
+	defer srv.Close()
+	st := newCountingStore(t)
+	pool := utils.NewTokenPool(3)
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry}, sns.WithStore(st), sns.WithTokenPool(pool))
+
+	if _, errs := collect(t, scraper.TweetSearch(context.Background(), "from:alice", 100)); len(errs) > 0 {
+		t.Fatalf("errors %v", errs)
+	}
+	if got := srv.Requests(twittertest.EndpointSearch); got < 10 {
+		t.Fatalf("%d search requests, want the pool to go round several times", got)
+	}
+	// one write per token fetched, none for handing out the pool round robin
+	if got := st.count(sns.StoreKeyGuestTokens); got != 3 {
+		t.Fatalf("guest tokens saved %d times, want 3", got)
+	}
+
+	pool.Retire(pool.Tokens()[0].Value)
+	if _, errs := collect(t, scraper.TweetSearch(context.Background(), "from:alice", 1)); len(errs) > 0 {
+		t.Fatalf("errors %v", errs)
+	}
+	if got := st.count(sns.StoreKeyGuestTokens); got != 4 {
+		t.Fatalf("guest tokens saved %d times, want once more after a retirement", got)
+	}
+}
+
+func TestStoreCookiesRoundTrip(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: fakeTweets(2)})
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "store.json")
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry}, sns.WithStore(store.NewFileStore(path)))
+	if _, errs := collect(t, scraper.TweetSearch(context.Background(), "from:alice", 100)); len(errs) > 0 {
+		t.Fatalf("errors %v", errs)
+	}
+	if srv.Requests(twittertest.EndpointPage) == 0 {
+		t.Fatal("no page request set the cookies")
+	}
+
+	cookies := func(st store.Store) []map[string]interface{} {
+		t.Helper()
+		data, err := st.Get(context.Background(), sns.StoreKeyCookies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cookies []map[string]interface{}
+		if err := json.Unmarshal(data, &cookies); err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(cookies, func(i, j int) bool {
+			return cookies[i]["name"].(string) < cookies[j]["name"].(string)
+		})
+		return cookies
+	}
+	// the session cookie is not kept
+	saved := cookies(store.NewFileStore(path))
+	names := make(map[string]interface{})
+	for _, cookie := range saved {
+		names[cookie["name"].(string)] = cookie["value"]
+	}
+	if _, ok := names["_twitter_sess"]; ok || names["guest_id"] != "v1%3A161000000000000000" {
+		t.Fatalf("cookies %v, want the guest_id and no session cookie", saved)
+	}
+
+	// a new scraper loads the cookies, flushing an empty jar would delete them
+	st := newCountingStore(t)
+	data, _ := store.NewFileStore(path).Get(context.Background(), sns.StoreKeyCookies)
+	if err := st.Set(context.Background(), sns.StoreKeyCookies, data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	next := newFakeScraper(t, srv, sns.Config{Retry: fastRetry}, sns.WithStore(st))
+	if err := next.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := cookies(st); !reflect.DeepEqual(got, saved) {
+		t.Fatalf("cookies %v after a reload, want %v", got, saved)
+	}
+}
@@ -0,0 +1,178 @@
+package sns
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// connectProxy is a local stand-in of an HTTPS proxy, tunneling CONNECT
+// requests to their target.
+func connectProxy(t *testing.T, connects *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		atomic.AddInt32(connects, 1)
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		go func() {
+			io.Copy(target, buf)
+			target.Close()
+		}()
+		io.Copy(conn, target)
+		conn.Close()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testConfig() *Config {
+	return &Config{Date: DateRange{Since: "2021-01-01", Until: "2021-12-31"}}
+}
+
+func TestProxyPoolConnect(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(target.Certificate())
+
+	var connects [2]int32
+	pool, err := NewProxyPool([]string{
+		connectProxy(t, &connects[0]).URL,
+		connectProxy(t, &connects[1]).URL,
+	}, ProxyPoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scraper, err := NewTwitterScraper(testConfig(), WithProxyPool(pool), WithTLSConfig(&tls.Config{RootCAs: roots}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		resp, err := scraper.scraper.RequestGETContext(context.Background(), target.URL, "", nil, nil)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "ok" {
+			t.Fatalf("request %d: body %q", i, body)
+		}
+	}
+	for i := range connects {
+		if atomic.LoadInt32(&connects[i]) == 0 {
+			t.Errorf("proxy %d got no CONNECT", i)
+		}
+	}
+	for _, stat := range scraper.ProxyStats() {
+		if stat.Requests != 2 || stat.Failures != 0 || stat.Quarantined {
+			t.Errorf("stats %+v, want 2 healthy requests", stat)
+		}
+	}
+}
+
+type stubTransport struct{}
+
+func (stubTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("not used")
+}
+
+func TestProxyPoolNeedsTransport(t *testing.T) {
+	pool, err := NewProxyPool([]string{"http://127.0.0.1:1"}, ProxyPoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"proxy pool", []Option{WithTransport(stubTransport{}), WithProxyPool(pool)}},
+		{"tls config", []Option{WithTransport(stubTransport{}), WithTLSConfig(&tls.Config{})}},
+		{"client transport", []Option{WithHTTPClient(&http.Client{Transport: stubTransport{}}), WithProxyPool(pool)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTwitterScraper(testConfig(), tt.opts...); err == nil {
+				t.Fatal("NewTwitterScraper accepted a RoundTripper the options cannot apply to")
+			}
+		})
+	}
+
+	if _, err := NewTwitterScraper(testConfig(), WithTransport(&http.Transport{}), WithProxyPool(pool)); err != nil {
+		t.Fatalf("*http.Transport: %v", err)
+	}
+}
+
+// hangingProxy is a proxy that never answers.
+func hangingProxy(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProxyPoolTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		// timeout of the request attempt, cancel of the caller ctx
+		timeout, cancel time.Duration
+		failures        int
+	}{
+		{"request timeout", 20 * time.Millisecond, 0, 1},
+		{"caller canceled", 0, 20 * time.Millisecond, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := NewProxyPool([]string{hangingProxy(t).URL}, ProxyPoolConfig{MaxFailures: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := testConfig()
+			conf.Retry = &RetryPolicy{MaxAttempts: 1}
+			scraper, err := NewTwitterScraper(conf, WithProxyPool(pool), WithRequestTimeout(tt.timeout))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			if tt.cancel > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.cancel)
+				defer cancel()
+			}
+			if _, err := scraper.scraper.RequestGETContext(ctx, "http://twitter.invalid/", "", nil, nil); err == nil {
+				t.Fatal("request through a hanging proxy succeeded")
+			}
+
+			stat := pool.Stats()[0]
+			if stat.Failures != tt.failures || stat.Quarantined != (tt.failures > 0) || stat.Requests != tt.failures {
+				t.Fatalf("stats %+v, want %d failures", stat, tt.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package sns
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	regexScreenName = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+	regexTag        = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+	regexCashtag    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.]{0,9}$`)
+	regexRadius     = regexp.MustCompile(`^\d+(\.\d+)?(km|mi)$`)
+	// regexQueryLang and regexQueryDates find the operators a query sets
+	// in place of Config.Lang and Config.Date
+	regexQueryLang  = regexp.MustCompile(`(^|[\s(])lang:`)
+	regexQueryDates = regexp.MustCompile(`(^|[\s(])(since|until)(_time)?:`)
+)
+
+// SearchFilter is a filter: operator of the search query.
+type SearchFilter string
+
+const (
+	FilterMedia    SearchFilter = "media"
+	FilterImages   SearchFilter = "images"
+	FilterVideos   SearchFilter = "videos"
+	FilterLinks    SearchFilter = "links"
+	FilterReplies  SearchFilter = "replies"
+	FilterRetweets SearchFilter = "nativeretweets"
+	FilterQuote    SearchFilter = "quote"
+	FilterVerified SearchFilter = "verified"
+)
+
+// QueryBuilder builds an advanced search query for TweetSearch. Terms are
+// rendered in the order they are added and all of them must match; use Or
+// and Not for alternatives and exclusions:
+//
+//	q := NewQuery().Hashtag("golang").
+//		Or(NewQuery().From("golang"), NewQuery().Mention("golang")).
+//		Not(NewQuery().Filter(FilterReplies)).
+//		MinFaves(10)
+//
+// Invalid terms are reported by Build.
+type QueryBuilder struct {
+	terms   []string
+	errs    []string
+	since   time.Time
+	until   time.Time
+	sinceID int
+	maxID   int
+}
+
+func NewQuery() *QueryBuilder {
+	return &QueryBuilder{}
+}
+
+func (q *QueryBuilder) add(term string) *QueryBuilder {
+	q.terms = append(q.terms, term)
+	return q
+}
+
+func (q *QueryBuilder) fail(format string, args ...interface{}) *QueryBuilder {
+	q.errs = append(q.errs, fmt.Sprintf(format, args...))
+	return q
+}
+
+func (q *QueryBuilder) screenName(op, name string) (string, bool) {
+	name = strings.TrimPrefix(name, "@")
+	if !regexScreenName.MatchString(name) {
+		q.fail("%s: invalid screen name %q", op, name)
+		return "", false
+	}
+	return name, true
+}
+
+// Raw adds a query fragment as is, e.g. a query written by hand.
+func (q *QueryBuilder) Raw(query string) *QueryBuilder {
+	if query = strings.TrimSpace(query); query != "" {
+		q.add(query)
+	}
+	return q
+}
+
+// Words adds keywords that must all appear, in any order.
+func (q *QueryBuilder) Words(words ...string) *QueryBuilder {
+	for _, word := range words {
+		switch {
+		case word == "":
+			q.fail("word: empty")
+		case strings.ContainsAny(word, " \t\n\""):
+			q.fail("word: %q contains spaces or quotes, use Phrase", word)
+		default:
+			q.add(word)
+		}
+	}
+	return q
+}
+
+// Phrase adds an exact phrase.
+func (q *QueryBuilder) Phrase(phrase string) *QueryBuilder {
+	phrase = strings.TrimSpace(phrase)
+	if phrase == "" || strings.Contains(phrase, `"`) {
+		return q.fail("phrase: invalid phrase %q", phrase)
+	}
+	return q.add(`"` + phrase + `"`)
+}
+
+// From matches tweets sent by the account.
+func (q *QueryBuilder) From(screenName string) *QueryBuilder {
+	if name, ok := q.screenName("from", screenName); ok {
+		q.add("from:" + name)
+	}
+	return q
+}
+
+// To matches replies to the account.
+func (q *QueryBuilder) To(screenName string) *QueryBuilder {
+	if name, ok := q.screenName("to", screenName); ok {
+		q.add("to:" + name)
+	}
+	return q
+}
+
+// Mention matches tweets mentioning the account.
+func (q *QueryBuilder) Mention(screenName string) *QueryBuilder {
+	if name, ok := q.screenName("mention", screenName); ok {
+		q.add("@" + name)
+	}
+	return q
+}
+
+// Hashtag matches the hashtag, with or without its leading #.
+func (q *QueryBuilder) Hashtag(tag string) *QueryBuilder {
+	tag = strings.TrimPrefix(tag, "#")
+	if !regexTag.MatchString(tag) {
+		return q.fail("hashtag: invalid hashtag %q", tag)
+	}
+	return q.add("#" + tag)
+}
+
+// Cashtag matches the ticker symbol, with or without its leading $.
+func (q *QueryBuilder) Cashtag(symbol string) *QueryBuilder {
+	symbol = strings.TrimPrefix(symbol, "$")
+	if !regexCashtag.MatchString(symbol) {
+		return q.fail("cashtag: invalid cashtag %q", symbol)
+	}
+	return q.add("$" + symbol)
+}
+
+// Or matches when any of the alternatives matches.
+func (q *QueryBuilder) Or(alternatives ...*QueryBuilder) *QueryBuilder {
+	if len(alternatives) < 2 {
+		return q.fail("or: needs at least 2 alternatives, got %d", len(alternatives))
+	}
+	parts := make([]string, 0, len(alternatives))
+	for _, alt := range alternatives {
+		if !q.merge("or", alt) {
+			return q
+		}
+		parts = append(parts, alt.group())
+	}
+	return q.add("(" + strings.Join(parts, " OR ") + ")")
+}
+
+// Not excludes the tweets matching sub.
+func (q *QueryBuilder) Not(sub *QueryBuilder) *QueryBuilder {
+	if !q.merge("not", sub) {
+		return q
+	}
+	return q.add("-" + sub.group())
+}
+
+// merge takes over the errors of a sub query, it returns whether sub can be
+// rendered.
+func (q *QueryBuilder) merge(op string, sub *QueryBuilder) bool {
+	if sub == nil {
+		q.fail("%s: empty query", op)
+		return false
+	}
+	for _, err := range sub.errs {
+		q.fail("%s: %s", op, err)
+	}
+	if len(sub.errs) > 0 {
+		return false
+	}
+	if len(sub.terms) == 0 {
+		q.fail("%s: empty query", op)
+		return false
+	}
+	if !sub.since.IsZero() || !sub.until.IsZero() || sub.sinceID != 0 || sub.maxID != 0 {
+		q.fail("%s: since, until, since_id and max_id apply to the whole query", op)
+		return false
+	}
+	return true
+}
+
+// group renders the terms, in parentheses when there is more than one.
+func (q *QueryBuilder) group() string {
+	if len(q.terms) == 1 {
+		return q.terms[0]
+	}
+	return "(" + strings.Join(q.terms, " ") + ")"
+}
+
+// MinFaves matches tweets with at least n likes.
+func (q *QueryBuilder) MinFaves(n int) *QueryBuilder {
+	return q.minimum("min_faves", n)
+}
+
+// MinRetweets matches tweets with at least n retweets.
+func (q *QueryBuilder) MinRetweets(n int) *QueryBuilder {
+	return q.minimum("min_retweets", n)
+}
+
+// MinReplies matches tweets with at least n replies.
+func (q *QueryBuilder) MinReplies(n int) *QueryBuilder {
+	return q.minimum("min_replies", n)
+}
+
+func (q *QueryBuilder) minimum(op string, n int) *QueryBuilder {
+	if n < 0 {
+		return q.fail("%s: negative count %d", op, n)
+	}
+	return q.add(op + ":" + strconv.Itoa(n))
+}
+
+// Filter keeps only the tweets of the kind, Not(NewQuery().Filter(f))
+// excludes them.
+func (q *QueryBuilder) Filter(filter SearchFilter) *QueryBuilder {
+	if filter == "" || strings.ContainsAny(string(filter), " :") {
+		return q.fail("filter: invalid filter %q", filter)
+	}
+	return q.add("filter:" + string(filter))
+}
+
+// Lang matches tweets in the language, an ISO 639-1 code.
+func (q *QueryBuilder) Lang(code string) *QueryBuilder {
+	if len(code) < 2 || len(code) > 3 || strings.ToLower(code) != code {
+		return q.fail("lang: invalid language code %q", code)
+	}
+	return q.add("lang:" + code)
+}
+
+// Geocode matches geotagged tweets within radius, e.g. "10km" or "5mi", of
+// the coordinates.
+func (q *QueryBuilder) Geocode(latitude, longitude float64, radius string) *QueryBuilder {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return q.fail("geocode: invalid coordinates %v,%v", latitude, longitude)
+	}
+	if !regexRadius.MatchString(radius) {
+		return q.fail("geocode: invalid radius %q", radius)
+	}
+	return q.add(fmt.Sprintf("geocode:%s,%s,%s",
+		strconv.FormatFloat(latitude, 'f', -1, 64), strconv.FormatFloat(longitude, 'f', -1, 64), radius))
+}
+
+// Near matches tweets sent near a named place, within radius when not empty.
+func (q *QueryBuilder) Near(place string, radius string) *QueryBuilder {
+	place = strings.TrimSpace(place)
+	if place == "" || strings.Contains(place, `"`) {
+		return q.fail("near: invalid place %q", place)
+	}
+	if radius != "" && !regexRadius.MatchString(radius) {
+		return q.fail("near: invalid radius %q", radius)
+	}
+	term := "near:" + place
+	if strings.Contains(place, " ") {
+		term = `near:"` + place + `"`
+	}
+	if radius != "" {
+		term += " within:" + radius
+	}
+	return q.add(term)
+}
+
+// URL matches tweets linking to an address containing s.
+func (q *QueryBuilder) URL(s string) *QueryBuilder {
+	if s == "" || strings.ContainsAny(s, " \t\n\"") {
+		return q.fail("url: invalid url %q", s)
+	}
+	return q.add("url:" + s)
+}
+
+// ConversationID matches the tweets of a conversation.
+func (q *QueryBuilder) ConversationID(id int) *QueryBuilder {
+	if id <= 0 {
+		return q.fail("conversation_id: invalid id %d", id)
+	}
+	return q.add("conversation_id:" + strconv.Itoa(id))
+}
+
+// SinceID matches tweets newer than the tweet id.
+func (q *QueryBuilder) SinceID(id int) *QueryBuilder {
+	if id <= 0 {
+		return q.fail("since_id: invalid id %d", id)
+	}
+	q.sinceID = id
+	return q
+}
+
+// MaxID matches tweets older than or equal to the tweet id.
+func (q *QueryBuilder) MaxID(id int) *QueryBuilder {
+	if id <= 0 {
+		return q.fail("max_id: invalid id %d", id)
+	}
+	q.maxID = id
+	return q
+}
+
+// Since matches tweets sent at or after t. Midnight UTC renders as since:
+// with the day, any other instant as since_time: with the unix time.
+func (q *QueryBuilder) Since(t time.Time) *QueryBuilder {
+	q.since = t
+	return q
+}
+
+// Until matches tweets sent before t, rendered like Since; at midnight the
+// day of t itself is excluded.
+func (q *QueryBuilder) Until(t time.Time) *QueryBuilder {
+	q.until = t
+	return q
+}
+
+// String renders the query, ignoring invalid terms.
+func (q *QueryBuilder) String() string {
+	terms := append([]string(nil), q.terms...)
+	if q.sinceID != 0 {
+		terms = append(terms, "since_id:"+strconv.Itoa(q.sinceID))
+	}
+	if q.maxID != 0 {
+		terms = append(terms, "max_id:"+strconv.Itoa(q.maxID))
+	}
+	if !q.since.IsZero() {
+		terms = append(terms, dateTerm("since", q.since))
+	}
+	if !q.until.IsZero() {
+		terms = append(terms, dateTerm("until", q.until))
+	}
+	return strings.Join(terms, " ")
+}
+
+func dateTerm(op string, t time.Time) string {
+	t = t.UTC()
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return op + ":" + t.Format(dateLayout)
+	}
+	return op + "_time:" + strconv.FormatInt(t.Unix(), 10)
+}
+
+// Build validates and renders the query. The error wraps ErrInvalidQuery.
+func (q *QueryBuilder) Build() (string, error) {
+	errs := append([]string(nil), q.errs...)
+	if !q.since.IsZero() && !q.until.IsZero() && !q.since.Before(q.until) {
+		errs = append(errs, "since must be before until")
+	}
+	if q.sinceID != 0 && q.maxID != 0 && q.sinceID >= q.maxID {
+		errs = append(errs, "since_id must be lower than max_id")
+	}
+	query := q.String()
+	if query == "" {
+		errs = append(errs, "empty query")
+	}
+	if len(errs) > 0 {
+		return "", newError(ErrInvalidQuery, "query", "%s", strings.Join(errs, "; "))
+	}
+	return query, nil
+}
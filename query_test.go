@@ -0,0 +1,164 @@
+package sns_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/twittertest"
+)
+
+func TestTweetSearchQueryOperators(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		name  string
+		query *sns.QueryBuilder
+		lang  sns.Lang
+		want  string
+	}{
+		{"config only", sns.NewQuery().Words("golang"), sns.LangID,
+			"golang lang:id since:2021-01-01 until:2021-02-01"},
+		{"config lang en", sns.NewQuery().Words("golang"), sns.LangEn,
+			"golang lang:en since:2021-01-01 until:2021-02-01"},
+		{"query dates", sns.NewQuery().Words("golang").Since(day("2022-03-01")).Until(day("2022-04-01")), sns.LangID,
+			"golang since:2022-03-01 until:2022-04-01 lang:id"},
+		{"query lang", sns.NewQuery().Words("golang").Lang("en"), sns.LangID,
+			"golang lang:en since:2021-01-01 until:2021-02-01"},
+		{"query lang and dates", sns.NewQuery().Words("golang").Lang("ja").Since(day("2022-03-01")), sns.LangEn,
+			"golang lang:ja since:2022-03-01"},
+		{"excluded lang", sns.NewQuery().Words("golang").Not(sns.NewQuery().Lang("en")), sns.LangID,
+			"golang -lang:en lang:id since:2021-01-01 until:2021-02-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers})
+			defer srv.Close()
+			scraper := newFakeScraper(t, srv, sns.Config{Lang: tt.lang})
+
+			if _, errs := collect(t, scraper.TweetSearchQuery(context.Background(), tt.query, 10)); len(errs) > 0 {
+				t.Fatalf("errors %v", errs)
+			}
+			params := srv.Params(twittertest.EndpointSearch)
+			if len(params) == 0 {
+				t.Fatal("no search request")
+			}
+			if got := params[0].Get("q"); got != tt.want {
+				t.Fatalf("q %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShardedSearchQueryDates(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Sharding: &sns.ShardPolicy{}})
+
+	q := sns.NewQuery().Words("golang").Since(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC))
+	_, errs := collect(t, scraper.TweetSearchQuery(context.Background(), q, 10))
+	if len(errs) != 1 || !errors.Is(errs[0], sns.ErrInvalidQuery) {
+		t.Fatalf("errors %v, want ErrInvalidQuery", errs)
+	}
+	if got := srv.Requests(twittertest.EndpointSearch); got != 0 {
+		t.Fatalf("%d search requests, want none", got)
+	}
+}
+
+func TestQueryBuilderBuild(t *testing.T) {
+	noon := time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+	midnight := time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query *sns.QueryBuilder
+		want  string
+		// errs are substrings of the Build error, none for a valid query
+		errs []string
+	}{
+		{
+			name:  "terms in order",
+			query: sns.NewQuery().Words("go", "generics").Phrase(" type parameters ").From("@golang").To("rob_pike").Mention("golang").Hashtag("#golang").Cashtag("$GOOG"),
+			want:  `go generics "type parameters" from:golang to:rob_pike @golang #golang $GOOG`,
+		},
+		{
+			name:  "operators",
+			query: sns.NewQuery().Raw(" gopher ").MinFaves(10).MinRetweets(0).MinReplies(2).Filter(sns.FilterMedia).Lang("id").URL("go.dev").ConversationID(42),
+			want:  "gopher min_faves:10 min_retweets:0 min_replies:2 filter:media lang:id url:go.dev conversation_id:42",
+		},
+		{
+			name:  "places",
+			query: sns.NewQuery().Geocode(-6.2, 106.816666, "10km").Near("San Francisco", "5mi").Near("Jakarta", ""),
+			want:  `geocode:-6.2,106.816666,10km near:"San Francisco" within:5mi near:Jakarta`,
+		},
+		{
+			name:  "or and not",
+			query: sns.NewQuery().Hashtag("golang").Or(sns.NewQuery().From("golang"), sns.NewQuery().Words("go", "lang")).Not(sns.NewQuery().Filter(sns.FilterReplies)),
+			want:  "#golang (from:golang OR (go lang)) -filter:replies",
+		},
+		{
+			name:  "ids and dates last",
+			query: sns.NewQuery().MaxID(200).Until(noon).Since(midnight.AddDate(0, 0, -2)).SinceID(100).Words("go"),
+			want:  "go since_id:100 max_id:200 since:2021-02-28 until_time:1614601800",
+		},
+		{
+			name:  "invalid terms",
+			query: sns.NewQuery().Words("", "two words").Phrase(`"`).From("not a name").Hashtag("#").Cashtag("1ABC").MinFaves(-1).Filter("a:b").Lang("EN").URL("").ConversationID(0),
+			errs: []string{
+				"word: empty", "use Phrase", "phrase: invalid", "from: invalid screen name", "hashtag: invalid", "cashtag: invalid",
+				"min_faves: negative", "filter: invalid", "lang: invalid", "url: invalid", "conversation_id: invalid",
+			},
+		},
+		{
+			name:  "invalid places",
+			query: sns.NewQuery().Geocode(91, 0, "10km").Geocode(0, 0, "10 km").Near("", "").Near("Jakarta", "far"),
+			errs:  []string{"geocode: invalid coordinates", "geocode: invalid radius", "near: invalid place", "near: invalid radius"},
+		},
+		{
+			name:  "invalid sub queries",
+			query: sns.NewQuery().Words("go").Or(sns.NewQuery().From("golang")).Or(sns.NewQuery().Words("go"), nil).Not(sns.NewQuery().Since(noon).Words("x")).Not(sns.NewQuery().Hashtag("#")).Not(sns.NewQuery()),
+			errs:  []string{"or: needs at least 2", "or: empty query", "not: since, until", "not: hashtag: invalid", "not: empty query"},
+		},
+		{
+			name:  "inverted bounds",
+			query: sns.NewQuery().Words("go").Since(midnight).Until(noon).SinceID(5).MaxID(5),
+			errs:  []string{"since must be before until", "since_id must be lower than max_id"},
+		},
+		{
+			name:  "invalid ids",
+			query: sns.NewQuery().Words("go").SinceID(-1).MaxID(0),
+			errs:  []string{"since_id: invalid", "max_id: invalid"},
+		},
+		{
+			name:  "empty",
+			query: sns.NewQuery().Raw("  "),
+			errs:  []string{"empty query"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.Build()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Fatalf("query %q, want %q", got, tt.want)
+				}
+				return
+			}
+			if !errors.Is(err, sns.ErrInvalidQuery) || got != "" {
+				t.Fatalf("query %q error %v, want ErrInvalidQuery", got, err)
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not report %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package sns
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitStats budget of an endpoint as last announced by the API.
+type RateLimitStats struct {
+	Endpoint  string    `json:"endpoint"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+type rateBucket struct {
+	endpoint  string
+	limit     int
+	remaining int
+	reset     time.Time
+	next      time.Time
+}
+
+// RateLimiter paces requests per endpoint from the x-rate-limit-limit,
+// x-rate-limit-remaining and x-rate-limit-reset response headers. The budget
+// left is spread evenly until the reset, and once it is exhausted requests
+// block until the window resets instead of burning retries. Budgets are kept
+// per guest token, since that is what twitter accounts them against.
+// A RateLimiter is safe for concurrent use and may be shared by scrapers.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+	now     func() time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: make(map[string]*rateBucket), now: time.Now}
+}
+
+// rateLimitKey names the endpoint of a request, e.g. "adaptive.json" or
+// "UserTweetsAndReplies", so that rotated GraphQL query IDs share a budget.
+func rateLimitKey(req *http.Request) (string, string) {
+	endpoint := path.Base(req.URL.Path)
+	key := endpoint
+	if token := req.Header.Get("x-guest-token"); token != "" {
+		key += "#" + token
+	}
+	return key, endpoint
+}
+
+// Wait blocks until a request to the endpoint of req fits in its budget.
+func (l *RateLimiter) Wait(req *http.Request) error {
+	key, _ := rateLimitKey(req)
+	delay := l.reserve(key)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve books the next slot of key and returns how long to wait for it.
+func (l *RateLimiter) reserve(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	now := l.now()
+	if !ok {
+		return 0
+	}
+	if !now.Before(b.reset) {
+		// the window is over, the response will announce the next one
+		delete(l.buckets, key)
+		return 0
+	}
+
+	if b.remaining <= 0 {
+		// exhausted, the first request after the reset refreshes the budget
+		return b.reset.Sub(now)
+	}
+
+	start := now
+	if b.next.After(start) {
+		start = b.next
+	}
+	interval := b.reset.Sub(start) / time.Duration(b.remaining)
+	b.next = start.Add(interval)
+	b.remaining--
+	return start.Sub(now)
+}
+
+// Update records the rate limit headers of the response to req.
+func (l *RateLimiter) Update(req *http.Request, response *http.Response) {
+	key, endpoint := rateLimitKey(req)
+	header := response.Header
+	remaining, errRemaining := strconv.Atoi(header.Get("x-rate-limit-remaining"))
+	reset, errReset := strconv.ParseInt(header.Get("x-rate-limit-reset"), 10, 64)
+	if errRemaining != nil || errReset != nil {
+		if response.StatusCode != http.StatusTooManyRequests {
+			return
+		}
+		// rate limited without headers, hold the endpoint for a window
+		remaining, reset = 0, l.now().Add(15*time.Minute).Unix()
+	}
+	limit, _ := strconv.Atoi(header.Get("x-rate-limit-limit"))
+	if response.StatusCode == http.StatusTooManyRequests {
+		remaining = 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		// every guest token gets buckets, drop those of retired ones
+		l.evict()
+		b = &rateBucket{endpoint: endpoint}
+		l.buckets[key] = b
+	}
+	b.limit = limit
+	b.remaining = remaining
+	b.reset = time.Unix(reset, 0)
+}
+
+// evict drops the buckets whose window has reset.
+func (l *RateLimiter) evict() {
+	now := l.now()
+	for key, b := range l.buckets {
+		if !now.Before(b.reset) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Stats returns the budget of every endpoint and guest token whose window
+// has not reset yet, ordered by endpoint.
+func (l *RateLimiter) Stats() []RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evict()
+	stats := make([]RateLimitStats, 0, len(l.buckets))
+	for _, b := range l.buckets {
+		stats = append(stats, RateLimitStats{
+			Endpoint:  b.endpoint,
+			Limit:     b.limit,
+			Remaining: b.remaining,
+			Reset:     b.reset,
+		})
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Endpoint < stats[j].Endpoint
+	})
+	return stats
+}
+
+// RateLimits returns the budgets tracked by the scraper rate limiter.
+func (c *TwitterScraper) RateLimits() []RateLimitStats {
+	return c.scraper.limiter.Stats()
+}
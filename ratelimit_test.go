@@ -0,0 +1,105 @@
+package sns
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// limitedResponse answers with the rate limit headers of a budget.
+func limitedResponse(status, limit, remaining int, reset time.Time) *http.Response {
+	header := http.Header{}
+	header.Set("x-rate-limit-limit", strconv.Itoa(limit))
+	header.Set("x-rate-limit-remaining", strconv.Itoa(remaining))
+	header.Set("x-rate-limit-reset", strconv.FormatInt(reset.Unix(), 10))
+	return &http.Response{StatusCode: status, Header: header}
+}
+
+func guestRequest(endpoint, token string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "https://api.twitter.com/2/search/"+endpoint, nil)
+	req.Header.Set("x-guest-token", token)
+	return req
+}
+
+func TestRateLimiterPacing(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	tests := []struct {
+		name     string
+		response *http.Response
+		waits    []time.Duration
+	}{
+		{
+			name:     "budget spread until the reset",
+			response: limitedResponse(http.StatusOK, 180, 4, start.Add(8*time.Second)),
+			waits:    []time.Duration{0, 2 * time.Second, 4 * time.Second, 6 * time.Second, 8 * time.Second},
+		},
+		{
+			name:     "exhausted budget",
+			response: limitedResponse(http.StatusOK, 180, 0, start.Add(time.Minute)),
+			waits:    []time.Duration{time.Minute, time.Minute},
+		},
+		{
+			name:     "429 with budget left",
+			response: limitedResponse(http.StatusTooManyRequests, 180, 50, start.Add(time.Minute)),
+			waits:    []time.Duration{time.Minute},
+		},
+		{
+			name:     "429 without headers",
+			response: &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}},
+			waits:    []time.Duration{15 * time.Minute},
+		},
+		{
+			name:     "no headers",
+			response: &http.Response{StatusCode: http.StatusOK, Header: http.Header{}},
+			waits:    []time.Duration{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter()
+			l.now = func() time.Time { return start }
+			req := guestRequest("adaptive.json", "1")
+			l.Update(req, tt.response)
+
+			key, _ := rateLimitKey(req)
+			for i, want := range tt.waits {
+				if got := l.reserve(key); got != want {
+					t.Fatalf("request %d waits %v, want %v", i, got, want)
+				}
+			}
+			// other guest tokens have their own budget
+			if other, _ := rateLimitKey(guestRequest("adaptive.json", "2")); l.reserve(other) != 0 {
+				t.Fatal("another guest token waits")
+			}
+		})
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	l := NewRateLimiter()
+	l.now = func() time.Time { return now }
+
+	for _, token := range []string{"1", "2", "3"} {
+		l.Update(guestRequest("adaptive.json", token), limitedResponse(http.StatusOK, 180, 100, now.Add(15*time.Minute)))
+	}
+	if got := len(l.Stats()); got != 3 {
+		t.Fatalf("%d budgets, want one per guest token", got)
+	}
+
+	// the windows of retired tokens reset and are dropped
+	now = now.Add(16 * time.Minute)
+	l.Update(guestRequest("adaptive.json", "4"), limitedResponse(http.StatusOK, 180, 100, now.Add(15*time.Minute)))
+	if got := len(l.buckets); got != 1 {
+		t.Fatalf("%d buckets kept, want the one of the live token", got)
+	}
+	if stats := l.Stats(); len(stats) != 1 || stats[0].Endpoint != "adaptive.json" || stats[0].Remaining != 100 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	now = now.Add(16 * time.Minute)
+	if stats := l.Stats(); len(stats) != 0 {
+		t.Fatalf("stats %+v, want the reset windows dropped", stats)
+	}
+}
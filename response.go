@@ -28,7 +28,7 @@ func (c *TwitterScraper) CheckApiResponse(r *http.Response) (bool, string) {
 
 func (c *TwitterScraper) CheckTokenResponse(r *http.Response) (bool, string) {
 	if r.StatusCode != http.StatusOK {
-		c.tokenManager.Reset()
+		c.retireGuestToken()
 		return false, "non-200 response"
 	}
 	return true, ""
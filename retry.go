@@ -0,0 +1,132 @@
+package sns
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy decides which failed requests are retried and how long to
+// wait in between. Set it on Config.Retry, nil means DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts total number of attempts, the first one included.
+	MaxAttempts int
+	// BaseDelay wait before the first retry, doubled on every next one.
+	BaseDelay time.Duration
+	// MaxDelay upper bound of a single wait, jitter included.
+	MaxDelay time.Duration
+	// Jitter randomizes every wait by up to ±Jitter of its length, 0 to 1.
+	Jitter float64
+	// RetryStatuses response status codes worth retrying.
+	RetryStatuses []int
+	// RetryNetErrors retries transport failures such as timeouts, refused or
+	// reset connections. Cancellation of the request context is never retried.
+	RetryNetErrors bool
+	// RotateIdentity switches the user agent and the guest token before
+	// retrying a request answered with 429 or 403, when RetryStatuses
+	// retries them.
+	RotateIdentity bool
+	// BeforeRetry is called before every retry with the request about to be
+	// sent, which may be modified, and the failed response (its body already
+	// closed) or error of the previous attempt.
+	BeforeRetry func(attempt int, req *http.Request, resp *http.Response, err error)
+}
+
+// DefaultRetryPolicy 3 attempts waiting 2s then 4s with 20% jitter, retrying
+// 429, 403, 5xx gateway errors and network errors, rotating identity on 429
+// and 403.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		BaseDelay:      2 * time.Second,
+		MaxDelay:       time.Minute,
+		Jitter:         0.2,
+		RetryStatuses:  []int{http.StatusTooManyRequests, http.StatusForbidden, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryNetErrors: true,
+		RotateIdentity: true,
+	}
+}
+
+func (p *RetryPolicy) orDefault() RetryPolicy {
+	if p == nil {
+		return DefaultRetryPolicy()
+	}
+	policy := *p
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = time.Duration(math.MaxInt64)
+	}
+	if policy.Jitter < 0 {
+		policy.Jitter = 0
+	} else if policy.Jitter > 1 {
+		policy.Jitter = 1
+	}
+	return policy
+}
+
+func (p RetryPolicy) retryStatus(code int) bool {
+	for _, status := range p.RetryStatuses {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) retryError(req *http.Request, err error) bool {
+	if !p.RetryNetErrors || req.Context().Err() != nil {
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// backoff returns the wait before retry number attempt, starting at 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.Jitter > 0 {
+		delay *= 1 - p.Jitter + 2*p.Jitter*rand.Float64()
+	}
+	// compared as floats, MaxDelay may be math.MaxInt64
+	if delay >= float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rotateIdentity switches the user agent and the guest token of req before
+// it retries a request that was rate limited or blocked.
+func (c *TwitterScraper) rotateIdentity(attempt int, req *http.Request, resp *http.Response, err error) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden) {
+		return
+	}
+	c.randomUserAgent()
+	req.Header.Set("User-Agent", c.headers().Get("User-Agent"))
+
+	// token requests themselves carry no guest token
+	if req.Header.Get("x-guest-token") == "" {
+		return
+	}
+	c.retireGuestToken()
+	if err := c.ensureGuestToken(req.Context(), c.endpoints.Web+"/"); err == nil {
+		req.Header.Set("x-guest-token", c.tokenManager.GetToken())
+	}
+}
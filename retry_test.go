@@ -0,0 +1,46 @@
+package sns
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{"first", RetryPolicy{BaseDelay: time.Second}, 1, time.Second, time.Second},
+		{"doubled", RetryPolicy{BaseDelay: time.Second}, 3, 4 * time.Second, 4 * time.Second},
+		{"jitter", RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}, 2, time.Second, 3 * time.Second},
+		{"capped", RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, 10, 5 * time.Second, 5 * time.Second},
+		{"capped after jitter", RetryPolicy{BaseDelay: 4 * time.Second, MaxDelay: 5 * time.Second, Jitter: 1}, 1, 0, 5 * time.Second},
+		{"unbounded", RetryPolicy{BaseDelay: time.Second, Jitter: 1}, 100, 0, time.Duration(math.MaxInt64)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := (&tt.policy).orDefault()
+			for i := 0; i < 100; i++ {
+				if d := policy.backoff(tt.attempt); d < tt.min || d > tt.max {
+					t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultRetryPolicyRotates(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusForbidden} {
+		if !policy.retryStatus(status) {
+			t.Errorf("%d is not retried, so RotateIdentity never applies to it", status)
+		}
+	}
+	if policy.retryStatus(http.StatusNotFound) {
+		t.Error("404 is retried")
+	}
+}
@@ -0,0 +1,152 @@
+package sns
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hinha/go-social-network/entities"
+)
+
+// JobKind is the flow a Job runs.
+type JobKind int
+
+const (
+	// JobSearch a TweetSearch of Query.
+	JobSearch JobKind = iota
+	// JobUser a TweetUser of Query, a screen name.
+	JobUser
+	// JobUserSearch a UserSearch of Query.
+	JobUserSearch
+)
+
+func (k JobKind) String() string {
+	switch k {
+	case JobSearch:
+		return "search"
+	case JobUser:
+		return "user"
+	case JobUserSearch:
+		return "user_search"
+	}
+	return "unknown"
+}
+
+// Job is one query run by a Runner.
+type Job struct {
+	Kind  JobKind
+	Query string
+	// Max results of the job.
+	Max int
+}
+
+// JobResult is a result of a Job: a tweet, an account of a JobUserSearch, or
+// an error.
+type JobResult struct {
+	Job   Job
+	Tweet *entities.TwitterPost
+	User  *entities.TwitterUser
+	Error error
+}
+
+// Runner runs jobs on a scraper, at most Workers at a time. The jobs share
+// the guest token pool and the rate limiter of the scraper. A Runner is safe
+// for concurrent use, the worker limit applies across all Run calls.
+type Runner struct {
+	scraper *TwitterScraper
+	workers chan struct{}
+}
+
+// DefaultWorkers is the worker count of NewRunner when workers is not
+// positive.
+const DefaultWorkers = 4
+
+// NewRunner creates a runner of workers concurrent jobs on scraper.
+func NewRunner(scraper *TwitterScraper, workers int) *Runner {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	return &Runner{scraper: scraper, workers: make(chan struct{}, workers)}
+}
+
+// Run queues the jobs and returns their results, interleaved. The jobs are
+// fed to at most Workers goroutines, however many are queued. The channel is
+// closed once every job is done or ctx is canceled.
+func (r *Runner) Run(ctx context.Context, jobs ...Job) <-chan *JobResult {
+	channel := make(chan *JobResult)
+	queue := make(chan Job)
+	go func() {
+		defer close(queue)
+		for _, job := range jobs {
+			select {
+			case queue <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	workers := cap(r.workers)
+	if len(jobs) < workers {
+		workers = len(jobs)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				select {
+				case r.workers <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				r.run(ctx, job, channel)
+				<-r.workers
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(channel)
+	}()
+	return channel
+}
+
+// run forwards the results of job to channel.
+func (r *Runner) run(ctx context.Context, job Job, channel chan<- *JobResult) {
+	// the job ctx stops the scrape when the consumer goes away
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	send := func(result *JobResult) bool {
+		select {
+		case channel <- result:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	switch job.Kind {
+	case JobSearch, JobUser:
+		var tweets <-chan *TweetResult
+		if job.Kind == JobSearch {
+			tweets = r.scraper.TweetSearch(ctx, job.Query, job.Max)
+		} else {
+			tweets = r.scraper.TweetUser(ctx, job.Query, job.Max)
+		}
+		for tweet := range tweets {
+			if !send(&JobResult{Job: job, Tweet: tweet.TwitterPost, Error: tweet.Error}) {
+				return
+			}
+		}
+	case JobUserSearch:
+		for user := range r.scraper.UserSearch(ctx, job.Query, job.Max) {
+			if !send(&JobResult{Job: job, User: user.TwitterUser, Error: user.Error}) {
+				return
+			}
+		}
+	default:
+		send(&JobResult{Job: job, Error: newError(ErrInvalidQuery, "runner", "unknown job kind %d", job.Kind)})
+	}
+}
@@ -0,0 +1,137 @@
+package sns_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/twittertest"
+)
+
+// slowSearch delays the search requests and records the most sent at once.
+type slowSearch struct {
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (s *slowSearch) RoundTrip(req *http.Request) (*http.Response, error) {
+	if path.Base(req.URL.Path) != twittertest.EndpointSearch {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	s.mu.Lock()
+	s.running++
+	if s.running > s.peak {
+		s.peak = s.running
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRunnerWorkers(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: fakeTweets(3)})
+	defer srv.Close()
+	slow := &slowSearch{}
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry}, sns.WithTransport(slow))
+
+	jobs := make([]sns.Job, 8)
+	for i := range jobs {
+		jobs[i] = sns.Job{Kind: sns.JobSearch, Query: "from:alice", Max: 100}
+	}
+	runner := sns.NewRunner(scraper, 2)
+	// two Run calls share the worker limit
+	var wg sync.WaitGroup
+	counts := make([]int, 2)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for r := range runner.Run(context.Background(), jobs...) {
+				if r.Error != nil {
+					t.Error(r.Error)
+				}
+				counts[i]++
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, n := range counts {
+		if n != len(jobs)*3 {
+			t.Errorf("run %d: %d results, want %d", i, n, len(jobs)*3)
+		}
+	}
+	if slow.peak > 2 {
+		t.Fatalf("%d searches at once, want at most 2", slow.peak)
+	}
+}
+
+func TestRunnerBoundedGoroutines(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: fakeTweets(3)})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs := make([]sns.Job, 1000)
+	for i := range jobs {
+		jobs[i] = sns.Job{Kind: sns.JobSearch, Query: "from:alice", Max: 100}
+	}
+	before := runtime.NumGoroutine()
+	results := sns.NewRunner(scraper, 2).Run(ctx, jobs...)
+	// the first results are taken, the rest of the jobs stay queued
+	<-results
+	time.Sleep(20 * time.Millisecond)
+	if grown := runtime.NumGoroutine() - before; grown > 50 {
+		t.Fatalf("%d goroutines for 1000 queued jobs on 2 workers", grown)
+	}
+
+	cancel()
+	for range results {
+	}
+}
+
+func TestRunnerErrors(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: fakeTweets(2)})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	jobs := []sns.Job{
+		{Kind: sns.JobSearch, Query: "from:alice", Max: 100},
+		{Kind: sns.JobUser, Query: "nobody", Max: 100},
+		{Kind: sns.JobKind(42), Query: "x"},
+	}
+	var tweets int
+	errs := make(map[sns.JobKind]error)
+	for r := range sns.NewRunner(scraper, 3).Run(context.Background(), jobs...) {
+		if r.Error != nil {
+			errs[r.Job.Kind] = r.Error
+		}
+		if r.Tweet != nil {
+			tweets++
+		}
+	}
+	if tweets != 2 {
+		t.Errorf("%d tweets, want the 2 of the search", tweets)
+	}
+	var unavailable *sns.UserUnavailableError
+	if !errors.As(errs[sns.JobUser], &unavailable) {
+		t.Errorf("user job error %v, want *UserUnavailableError", errs[sns.JobUser])
+	}
+	if !errors.Is(errs[sns.JobKind(42)], sns.ErrInvalidQuery) {
+		t.Errorf("unknown job error %v, want ErrInvalidQuery", errs[sns.JobKind(42)])
+	}
+	if err := errs[sns.JobSearch]; err != nil {
+		t.Errorf("search job error %v", err)
+	}
+}
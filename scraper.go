@@ -1,9 +1,10 @@
 package sns
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"net/http/cookiejar"
 	"time"
@@ -11,11 +12,28 @@ import (
 	"github.com/hinha/go-social-network/logger"
 )
 
+// DateRange are the days, as "2006-01-02", searched by TweetSearch and
+// kept by TweetUser. Until is inclusive, an empty range is not limited.
 type DateRange struct {
 	Since string
 	Until string
 }
 
+// window parses the range as [since, until), zero when unset.
+func (d DateRange) window() (since, until time.Time, err error) {
+	if d.Since == "" && d.Until == "" {
+		return time.Time{}, time.Time{}, nil
+	}
+	if since, err = time.Parse(dateLayout, d.Since); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("sns: Config.Date.Since: %w", err)
+	}
+	if until, err = time.Parse(dateLayout, d.Until); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("sns: Config.Date.Until: %w", err)
+	}
+	// the until day is inclusive
+	return since, until.AddDate(0, 0, 1), nil
+}
+
 type (
 	callbackResponse func(r *http.Response) (bool, string)
 
@@ -31,6 +49,14 @@ const (
 	LangEn
 )
 
+// code returns the ISO 639-1 code of the language.
+func (l Lang) code() string {
+	if l == LangEn {
+		return "en"
+	}
+	return "id"
+}
+
 // Config struct contain extra config of twitter
 type Config struct {
 	// Logger
@@ -39,127 +65,264 @@ type Config struct {
 	Date DateRange
 	Lang Lang
 	SearchMode
+	// SearchTab of keyword and hashtag searches, Latest by default.
+	SearchTab SearchTab
+
+	// Retry policy of every request, nil means DefaultRetryPolicy.
+	Retry *RetryPolicy
+	// Endpoints overrides the API URLs, nil means DefaultEndpoints.
+	Endpoints *Endpoints
+	// Sharding splits TweetSearch over Date into time shards, nil searches
+	// the whole range at once. Sharded searches cannot be resumed.
+	Sharding *ShardPolicy
 }
 
 // Scraper object
 type Scraper struct {
 	conf    *Config
 	client  *http.Client
+	timeout time.Duration
+	proxies *ProxyPool
+	jar     *persistentJar
+	limiter *RateLimiter
+	retry   RetryPolicy
 	retries int
+	// since and until are Config.Date, zero when unset
+	since, until time.Time
+
+	// beforeRetry is the TwitterScraper hook rotating identity on retries.
+	beforeRetry func(attempt int, req *http.Request, resp *http.Response, err error)
 }
 
-func newScraper(conf *Config) *Scraper {
-	jar, err := cookiejar.New(nil)
+func newScraper(conf *Config, opts options) (*Scraper, error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if opts.client != nil {
+		// the caller keeps its client as it was given
+		c := *opts.client
+		client = &c
+	}
+	if client.Jar == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		client.Jar = jar
+	}
+	rt, err := opts.roundTripper(client.Transport)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	client.Transport = rt
+	var jar *persistentJar
+	if opts.store != nil {
+		jar = newPersistentJar(client.Jar)
+		client.Jar = jar
 	}
 
 	s := &Scraper{
-		client: &http.Client{
-			Timeout: 10 * time.Second, // default timeout
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-			Jar: jar,
-		},
+		client:  client,
+		timeout: opts.timeout,
+		proxies: opts.proxies,
+		jar:     jar,
+		limiter: opts.limiter,
+	}
+	if s.limiter == nil {
+		s.limiter = NewRateLimiter()
 	}
 
+	s.retry = DefaultRetryPolicy()
 	if conf != nil {
-		if _, err := time.Parse(dateLayout, conf.Date.Since); err != nil {
-			panic(fmt.Errorf("since %v", err))
-		} else {
-			conf.Date.Since += " 00:00:00"
-		}
-
-		if _, err := time.Parse(dateLayout, conf.Date.Until); err != nil {
-			panic(fmt.Errorf("until %v", err))
-		} else {
-			conf.Date.Until += " 23:59:59"
+		if s.since, s.until, err = conf.Date.window(); err != nil {
+			return nil, err
 		}
 		s.conf = conf
+		s.retry = conf.Retry.orDefault()
 	}
-	s.retries = 3
+	s.retries = s.retry.MaxAttempts
 
-	return s
+	return s, nil
 }
 
 func (c *Scraper) RequestGET(url string, paramEncode string, header http.Header, cb callbackResponse) (response *http.Response, err error) {
-	return c.newRequest("GET", url, paramEncode, nil, header, 10, cb)
+	return c.newRequest(context.Background(), "GET", url, paramEncode, nil, header, c.timeout, cb)
 }
 
 func (c *Scraper) RequestPOST(url string, paramEncode string, body io.Reader, header http.Header, cb callbackResponse) (response *http.Response, err error) {
-	return c.newRequest("POST", url, paramEncode, body, header, 10, cb)
+	return c.newRequest(context.Background(), "POST", url, paramEncode, body, header, c.timeout, cb)
+}
+
+// RequestGETContext is RequestGET bound to ctx: cancelling it aborts the
+// request in flight, the rate limit waits and the retry backoff.
+func (c *Scraper) RequestGETContext(ctx context.Context, url string, paramEncode string, header http.Header, cb callbackResponse) (response *http.Response, err error) {
+	return c.newRequest(ctx, "GET", url, paramEncode, nil, header, c.timeout, cb)
+}
+
+// RequestPOSTContext is RequestPOST bound to ctx.
+func (c *Scraper) RequestPOSTContext(ctx context.Context, url string, paramEncode string, body io.Reader, header http.Header, cb callbackResponse) (response *http.Response, err error) {
+	return c.newRequest(ctx, "POST", url, paramEncode, body, header, c.timeout, cb)
 }
 
 func (c *Scraper) GetClient() *http.Client {
 	return c.client
 }
 
-func (c *Scraper) newRequest(method, urls string, paramEncode string, body io.Reader, header http.Header, timeout int, cb callbackResponse) (response *http.Response, err error) {
+// newRequest sends the request, retrying it as the retry policy says. The
+// body is buffered so that POST requests are replayed identically. A final
+// non-2xx response is returned as is for the caller to classify.
+func (c *Scraper) newRequest(ctx context.Context, method, urls string, paramEncode string, body io.Reader, header http.Header, timeout time.Duration, cb callbackResponse) (response *http.Response, err error) {
 	currentLogger, newLogger := c.conf.Logger, logger.Recorder.New()
 
-	c.client.Timeout = time.Duration(timeout) * time.Second
 	urls += paramEncode
-	req, err := http.NewRequest(method, urls, body)
-	if err != nil {
-		return nil, err
+	var payload []byte
+	if body != nil {
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
 	}
-	currentLogger.Init(map[string]interface{}{
-		"subject": "request",
-		"method":  method,
-		"path":    req.URL.Path,
-	})
-
-	req.Header = header
-	var redirection []string
-	for i := 1; i < c.retries+1; i++ {
-		response, err = c.client.Do(req)
-		redirection = append(redirection, redirectionUrl(response)) // check redirect url
+
+	var req *http.Request
+	var attempt int
+	for attempt = 1; ; attempt++ {
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err = http.NewRequestWithContext(ctx, method, urls, reqBody)
 		if err != nil {
-			if cb != nil {
-				if len(redirection) != 0 {
-					for i, redirect := range redirection {
-						directLog := fmt.Sprintf("Request %d: %s: %d (Location: %v)", i, redirect, response.StatusCode, response.Header.Get("location"))
-						currentLogger.Debug(newLogger.BeginAt, directLog)
-					}
-				}
-
-				ok, msg := cb(response)
-				if ok {
-					currentLogger.Debug(newLogger.BeginAt, "retrieved successfully", msg)
-					break
-				} else {
-					if i < c.retries {
-						currentLogger.Info(newLogger.BeginAt, "Error retrieving ", req.URL.String(), ", retrying")
-					} else {
-						currentLogger.Error(newLogger.BeginAt, "Error retrieving", req.URL.String())
-					}
-				}
+			return nil, err
+		}
+		req.Header = header.Clone()
+		if attempt == 1 {
+			currentLogger.Init(map[string]interface{}{
+				"subject": "request",
+				"method":  method,
+				"path":    req.URL.Path,
+			})
+		} else {
+			if c.beforeRetry != nil {
+				c.beforeRetry(attempt, req, response, err)
 			}
+			if c.retry.BeforeRetry != nil {
+				c.retry.BeforeRetry(attempt, req, response, err)
+			}
+		}
 
-			if i < c.retries {
-				sleepTime := 1.0 * int(math.Pow(2, float64(i)))
-				currentLogger.Info(newLogger.BeginAt, fmt.Sprintf("Waiting %v seconds", sleepTime))
-				time.Sleep(time.Duration(sleepTime) * time.Second)
+		response, err = c.do(req, timeout)
+		if err == nil {
+			if redirect := redirectionUrl(response); redirect != "" {
+				directLog := fmt.Sprintf("Request %d: %s: %d (Location: %v)", attempt, redirect, response.StatusCode, response.Header.Get("location"))
+				currentLogger.Debug(newLogger.BeginAt, directLog)
+			}
+
+			ok, msg := true, ""
+			if cb != nil {
+				ok, msg = cb(response)
 			}
+			if ok {
+				currentLogger.Debug(newLogger.BeginAt, "retrieved successfully", msg)
+				break
+			}
+			if !c.retry.retryStatus(response.StatusCode) {
+				break
+			}
+		} else if !c.retry.retryError(req, err) {
+			break
+		}
+
+		if attempt >= c.retry.MaxAttempts {
+			currentLogger.Error(newLogger.BeginAt, "Error retrieving", req.URL.String())
+			break
+		}
+		currentLogger.Info(newLogger.BeginAt, "Error retrieving ", req.URL.String(), ", retrying")
+		if response != nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
 
+		delay := c.retry.backoff(attempt)
+		currentLogger.Info(newLogger.BeginAt, fmt.Sprintf("Waiting %v", delay))
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
 		}
 	}
 
 	currentLogger.Trace(req.Context(), newLogger.BeginAt, func() (string, int) {
+		if response == nil {
+			return "", 0
+		}
 		return "", response.StatusCode
 	}, err)
 
 	if response == nil || err != nil {
-		currentLogger.Error(newLogger.BeginAt, fmt.Sprintf("%d request to %s failed, giving up: %v", c.retries+1, req.URL, err))
-		return nil, fmt.Errorf("reached unreachable code: %v", err)
+		currentLogger.Error(newLogger.BeginAt, fmt.Sprintf("%d request to %s failed, giving up: %v", attempt, req.URL, err))
+		return nil, fmt.Errorf("request %s failed after %d attempts: %w", req.URL.Path, attempt, err)
 	}
 
 	return response, nil
 }
 
+// do sends a single attempt once the rate limiter allows it, bounded by
+// timeout and through the next proxy of the pool if any. The timeout is
+// released when the response body is closed.
+func (c *Scraper) do(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if err := c.limiter.Wait(req); err != nil {
+		return nil, err
+	}
+	response, err := c.doProxy(req, timeout)
+	if err == nil {
+		c.limiter.Update(req, response)
+	}
+	return response, err
+}
+
+func (c *Scraper) doProxy(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if c.proxies != nil {
+		proxy, err := c.proxies.pick()
+		if err != nil {
+			return nil, err
+		}
+		req = withProxy(req, proxy)
+		response, err := c.send(req, timeout)
+		if err != nil && req.Context().Err() != nil {
+			// canceled by the caller, not timed out by the proxy
+			c.proxies.release(proxy)
+		} else {
+			c.proxies.report(proxy, response, err)
+		}
+		return response, err
+	}
+	return c.send(req, timeout)
+}
+
+func (c *Scraper) send(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return c.client.Do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	response, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	response.Body = &cancelBody{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+// ProxyStats returns the health of the proxy pool, nil without one.
+func (c *Scraper) ProxyStats() []ProxyStats {
+	if c.proxies == nil {
+		return nil
+	}
+	return c.proxies.Stats()
+}
+
 func redirectionUrl(resp *http.Response) string {
-	if resp.StatusCode == http.StatusMovedPermanently {
+	if resp != nil && resp.StatusCode == http.StatusMovedPermanently {
 		return resp.Request.URL.String()
 	}
 	return ""
@@ -0,0 +1,48 @@
+package sns
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewTwitterScraperDates(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(dateLayout, s)
+		return d
+	}
+	tests := []struct {
+		name         string
+		date         DateRange
+		since, until time.Time
+		fails        bool
+	}{
+		{"window", DateRange{Since: "2021-01-01", Until: "2021-01-31"}, day("2021-01-01"), day("2021-02-01"), false},
+		{"unset", DateRange{}, time.Time{}, time.Time{}, false},
+		{"invalid since", DateRange{Since: "2021-13-01", Until: "2021-01-31"}, time.Time{}, time.Time{}, true},
+		{"missing until", DateRange{Since: "2021-01-01"}, time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &Config{Date: tt.date}
+			// a config is reusable, e.g. by the scrapers of a Runner
+			for i := 0; i < 2; i++ {
+				scraper, err := NewTwitterScraper(conf)
+				if tt.fails {
+					if err == nil {
+						t.Fatalf("NewTwitterScraper accepted %+v", tt.date)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("scraper %d: %v", i, err)
+				}
+				if since, until := scraper.dateWindow(); !since.Equal(tt.since) || !until.Equal(tt.until) {
+					t.Fatalf("window %v - %v, want %v - %v", since, until, tt.since, tt.until)
+				}
+			}
+			if conf.Date != tt.date {
+				t.Fatalf("config date changed to %+v", conf.Date)
+			}
+		})
+	}
+}
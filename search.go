@@ -0,0 +1,284 @@
+package sns
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hinha/go-social-network/entities"
+)
+
+// SearchTab is the result tab of the search page.
+type SearchTab int
+
+const (
+	// TabLatest every matching tweet, newest first.
+	TabLatest SearchTab = iota
+	// TabTop the most relevant tweets.
+	TabTop
+	// TabPhotos tweets with images.
+	TabPhotos
+	// TabVideos tweets with videos.
+	TabVideos
+	// TabPeople matching accounts, see UserSearch.
+	TabPeople
+)
+
+func (t SearchTab) String() string {
+	switch t {
+	case TabLatest:
+		return "latest"
+	case TabTop:
+		return "top"
+	case TabPhotos:
+		return "photos"
+	case TabVideos:
+		return "videos"
+	case TabPeople:
+		return "people"
+	}
+	return "unknown"
+}
+
+// apply sets the adaptive.json parameters selecting the tab.
+func (t SearchTab) apply(params url.Values) {
+	params.Del("tweet_search_mode")
+	params.Del("result_filter")
+	switch t {
+	case TabLatest:
+		params.Set("tweet_search_mode", "live")
+	case TabPhotos:
+		params.Set("result_filter", "image")
+	case TabVideos:
+		params.Set("result_filter", "video")
+	case TabPeople:
+		params.Set("result_filter", "user")
+	}
+}
+
+// SearchResults of Search. Tweets is set for keyword and hashtag searches,
+// Users for user searches.
+type SearchResults struct {
+	Tweets <-chan *TweetResult
+	Users  <-chan *UserResult
+
+	hashtags *hashtagCounter
+}
+
+// HashtagCount is a hashtag found next to the searched one and the number of
+// tweets carrying both.
+type HashtagCount struct {
+	Hashtag string `json:"hashtag"`
+	Count   int    `json:"count"`
+}
+
+// CoOccurrence returns the hashtags of a hashtag search tweets other than
+// the searched one, most frequent first. It is complete once Tweets is
+// drained, and empty for other searches.
+func (r *SearchResults) CoOccurrence() []HashtagCount {
+	if r.hashtags == nil {
+		return nil
+	}
+	return r.hashtags.counts()
+}
+
+// Search runs the flow of Config.SearchMode: a keyword search in
+// Config.SearchTab, an account search or a hashtag search.
+func (c *TwitterScraper) Search(ctx context.Context, query string, max int) *SearchResults {
+	switch {
+	case c.config.SearchMode == SearchUser, c.config.SearchMode == SearchKeyword && c.config.SearchTab == TabPeople:
+		return &SearchResults{Users: c.UserSearch(ctx, query, max)}
+	case c.config.SearchMode == SearchHashtag:
+		return c.HashtagSearch(ctx, query, max)
+	default:
+		return &SearchResults{Tweets: c.TweetSearch(ctx, query, max)}
+	}
+}
+
+// UserSearch searches accounts matching query, the users vertical of the
+// search page.
+func (c *TwitterScraper) UserSearch(ctx context.Context, query string, maxUsers int) <-chan *UserResult {
+	params := c.searchParams(query, TabPeople, time.Time{}, time.Time{})
+	// the language and date range of the config only apply to tweets
+	params.Set("q", query)
+	params.Del("cursor")
+
+	channel := make(chan *UserResult)
+	go c.iteratorUsers(ctx, c.endpoints.Search+"?", params, maxUsers, channel)
+	return channel
+}
+
+// HashtagSearch searches the tweets carrying tag, given with or without its
+// leading #, in Config.SearchTab and counts the other hashtags of those
+// tweets.
+func (c *TwitterScraper) HashtagSearch(ctx context.Context, tag string, maxTweets int) *SearchResults {
+	tag = normalizeHashtag(tag)
+	query, err := NewQuery().Hashtag(tag).Build()
+	if err != nil {
+		return &SearchResults{Tweets: errorChannel(err)}
+	}
+
+	counter := &hashtagCounter{tag: tag, seen: make(map[string]int)}
+	tweets := c.TweetSearch(ctx, query, maxTweets)
+	channel := make(chan *TweetResult)
+	go func() {
+		defer close(channel)
+		for tweet := range tweets {
+			if tweet.TwitterPost != nil {
+				counter.add(tweet.TwitterPost)
+			}
+			if !sendResult(ctx, channel, tweet) {
+				return
+			}
+		}
+	}()
+	return &SearchResults{Tweets: channel, hashtags: counter}
+}
+
+// normalizeHashtag strips the leading # or fullwidth ＃ and surrounding
+// spaces of tag.
+func normalizeHashtag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	tag = strings.TrimPrefix(tag, "＃")
+	tag = strings.TrimPrefix(tag, "#")
+	return strings.TrimSpace(tag)
+}
+
+type hashtagCounter struct {
+	mu   sync.Mutex
+	tag  string
+	seen map[string]int
+}
+
+func (h *hashtagCounter) add(post *entities.TwitterPost) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tweetTags := make(map[string]bool)
+	for _, tag := range post.Hashtags {
+		tag = strings.ToLower(tag)
+		if tag == "" || strings.EqualFold(tag, h.tag) || tweetTags[tag] {
+			continue
+		}
+		tweetTags[tag] = true
+		h.seen[tag]++
+	}
+}
+
+func (h *hashtagCounter) counts() []HashtagCount {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts := make([]HashtagCount, 0, len(h.seen))
+	for tag, n := range h.seen {
+		counts = append(counts, HashtagCount{Hashtag: tag, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Hashtag < counts[j].Hashtag
+	})
+	return counts
+}
+
+// iteratorUsers pages through the users vertical of a search.
+func (c *TwitterScraper) iteratorUsers(ctx context.Context, endpoint string, params url.Values, maxUsers int, channel chan *UserResult) {
+	beginAt := time.Now()
+	defer close(channel)
+
+	var cursor string
+	var emptyResponseOnCursor, userNum int
+	for {
+		c.config.Logger.Info(beginAt, "Retrieving users page ", cursor)
+		obj, err := c.get_api_data(ctx, endpoint, params, APIStandart)
+		if err != nil {
+			sendUser(ctx, channel, &UserResult{Error: err})
+			return
+		}
+
+		// error results are passed on without counting toward maxUsers
+		for _, user := range parseUsers(obj) {
+			if userNum >= maxUsers || !sendUser(ctx, channel, user) {
+				return
+			}
+			if user.TwitterUser != nil {
+				userNum++
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		page, err := scanCursors(obj.Timeline.Instructions, APIStandart)
+		if err != nil {
+			sendUser(ctx, channel, &UserResult{Error: err})
+			return
+		}
+		if page.bottom == cursor && page.tweets == 0 {
+			emptyResponseOnCursor++
+			if emptyResponseOnCursor > c.scraper.retries {
+				return
+			}
+		}
+		if page.bottom == "" || (page.stopOnEmpty && page.tweets == 0) {
+			return
+		}
+
+		cursor = page.bottom
+		next := url.Values{}
+		for k, v := range params {
+			next[k] = v
+		}
+		next.Set("cursor", cursor)
+		params = next
+	}
+}
+
+// sendUser sends r unless ctx is done first.
+func sendUser(ctx context.Context, channel chan<- *UserResult, r *UserResult) bool {
+	select {
+	case channel <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// parseUsers returns the accounts of a users vertical page.
+func parseUsers(obj twitterResponse) []*UserResult {
+	users := make([]*UserResult, 0)
+	for _, instruction := range obj.Timeline.Instructions {
+		for _, e := range checkEntries(instruction) {
+			entry, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			entryID, _ := entry["entryId"].(string)
+			if !(strings.HasPrefix(entryID, "sq-I-u-") || strings.HasPrefix(entryID, "user-")) {
+				continue
+			}
+			user, err := parseUserEntry(entryID, entry, obj)
+			if user == nil && err == nil {
+				continue
+			}
+			users = append(users, &UserResult{TwitterUser: user, Error: err})
+		}
+	}
+	return users
+}
+
+func parseUserEntry(entryID string, entry map[string]interface{}, obj twitterResponse) (user *entities.TwitterUser, err error) {
+	defer recoverSchema("users", entryID, &err)
+	content := entry["content"].(map[string]interface{})["item"].(map[string]interface{})["content"].(map[string]interface{})
+	id := content["user"].(map[string]interface{})["id"].(string)
+	raw, ok := obj.GlobalObjects.Users[id]
+	if !ok {
+		return nil, nil
+	}
+	parsed := parseUser(raw, 0)
+	return &parsed, nil
+}
@@ -0,0 +1,194 @@
+package sns_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/twittertest"
+)
+
+// fakeAccounts returns n accounts with IDs from 1.
+func fakeAccounts(n int) []twittertest.User {
+	users := make([]twittertest.User, n)
+	for i := range users {
+		users[i] = twittertest.User{ID: i + 1, ScreenName: "user" + string(rune('a'+i)), Name: "User"}
+	}
+	return users
+}
+
+// collectUsers drains users and returns the IDs of the accounts and the
+// errors.
+func collectUsers(t *testing.T, users <-chan *sns.UserResult) (ids []int, errs []error) {
+	t.Helper()
+	for r := range users {
+		if r.TwitterUser != nil {
+			ids = append(ids, r.Id)
+		}
+		if r.Error != nil {
+			errs = append(errs, r.Error)
+		}
+	}
+	return ids, errs
+}
+
+func TestUserSearchMaxCountsUsers(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{
+		Users:    fakeAccounts(6),
+		PageSize: 3,
+		Faults:   twittertest.Faults{BrokenUsers: 2},
+	})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	ids, errs := collectUsers(t, scraper.UserSearch(context.Background(), "user", 3))
+	if want := []int{3, 4, 5}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("users %v, want %v", ids, want)
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], sns.ErrSchemaChanged) {
+		t.Fatalf("errors %v, want 2 ErrSchemaChanged", errs)
+	}
+}
+
+func TestSearchModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  sns.SearchMode
+		tab   sns.SearchTab
+		query string
+		// q is the search query sent, users whether accounts are returned
+		q     string
+		users bool
+	}{
+		{name: "keyword", mode: sns.SearchKeyword, query: "golang", q: "golang"},
+		{name: "user", mode: sns.SearchUser, query: "gopher", q: "gopher", users: true},
+		{name: "keyword on the people tab", mode: sns.SearchKeyword, tab: sns.TabPeople, query: "gopher", q: "gopher", users: true},
+		{name: "hashtag", mode: sns.SearchHashtag, query: " ＃golang ", q: "#golang"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: fakeTweets(2)})
+			defer srv.Close()
+			scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry, SearchMode: tt.mode, SearchTab: tt.tab})
+
+			results := scraper.Search(context.Background(), tt.query, 10)
+			if (results.Users != nil) != tt.users || (results.Tweets != nil) == tt.users {
+				t.Fatalf("results %+v, want users %v", results, tt.users)
+			}
+			var ids []int
+			var errs []error
+			if tt.users {
+				ids, errs = collectUsers(t, results.Users)
+			} else {
+				ids, errs = collect(t, results.Tweets)
+			}
+			if len(ids) == 0 || len(errs) > 0 {
+				t.Fatalf("results %v, errors %v", ids, errs)
+			}
+
+			// the config language and dates follow the query of tweet searches
+			params := srv.Params(twittertest.EndpointSearch)
+			if len(params) == 0 {
+				t.Fatal("no search request")
+			}
+			if q := params[0].Get("q"); (q != tt.q && !strings.HasPrefix(q, tt.q+" ")) || (tt.users && q != tt.q) {
+				t.Fatalf("q=%s, want %s", q, tt.q)
+			}
+			if filter := params[0].Get("result_filter"); (filter == "user") != tt.users {
+				t.Fatalf("result_filter %q", filter)
+			}
+		})
+	}
+}
+
+func TestHashtagSearchCoOccurrence(t *testing.T) {
+	tweets := fakeTweets(4)
+	tweets[0].Hashtags = []string{"golang", "Generics", "rust"}
+	tweets[1].Hashtags = []string{"Golang", "generics", "generics"}
+	tweets[2].Hashtags = []string{"golang", "rust"}
+	tweets[3].Hashtags = []string{"golang", "wasm"}
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: tweets})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	results := scraper.HashtagSearch(context.Background(), "#GoLang", 10)
+	if got := results.CoOccurrence(); len(got) != 0 {
+		t.Fatalf("co-occurrence %v before the search", got)
+	}
+	if ids, errs := collect(t, results.Tweets); len(ids) != 4 || len(errs) > 0 {
+		t.Fatalf("tweets %v, errors %v", ids, errs)
+	}
+	// counted once per tweet, case insensitive, the searched tag left out
+	want := []sns.HashtagCount{{Hashtag: "generics", Count: 2}, {Hashtag: "rust", Count: 2}, {Hashtag: "wasm", Count: 1}}
+	if got := results.CoOccurrence(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("co-occurrence %v, want %v", got, want)
+	}
+
+	if results := scraper.HashtagSearch(context.Background(), "#", 10); results.Tweets == nil {
+		t.Fatal("no tweets channel for an invalid hashtag")
+	} else if _, errs := collect(t, results.Tweets); len(errs) != 1 || !errors.Is(errs[0], sns.ErrInvalidQuery) {
+		t.Fatalf("errors %v, want ErrInvalidQuery", errs)
+	}
+}
+
+func TestSearchTabs(t *testing.T) {
+	tests := []struct {
+		tab sns.SearchTab
+		// mode and filter are the tweet_search_mode and result_filter sent
+		mode, filter string
+	}{
+		{tab: sns.TabLatest, mode: "live"},
+		{tab: sns.TabTop},
+		{tab: sns.TabPhotos, filter: "image"},
+		{tab: sns.TabVideos, filter: "video"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tab.String(), func(t *testing.T) {
+			srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: fakeTweets(2), PageSize: 1})
+			defer srv.Close()
+			scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry, SearchTab: tt.tab})
+
+			if ids, errs := collect(t, scraper.TweetSearch(context.Background(), "golang", 10)); len(ids) != 2 || len(errs) > 0 {
+				t.Fatalf("tweets %v, errors %v", ids, errs)
+			}
+			// every page of the search stays on the tab
+			params := srv.Params(twittertest.EndpointSearch)
+			if len(params) < 2 {
+				t.Fatalf("%d search requests, want the tweets paged", len(params))
+			}
+			for i, p := range params {
+				if p.Get("tweet_search_mode") != tt.mode || p.Get("result_filter") != tt.filter {
+					t.Fatalf("request %d: tweet_search_mode=%q result_filter=%q, want %q and %q",
+						i, p.Get("tweet_search_mode"), p.Get("result_filter"), tt.mode, tt.filter)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchTabPeople(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: fakeTweets(2)})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry, SearchTab: sns.TabPeople})
+
+	// the people tab lists accounts, TweetSearch refuses it
+	_, errs := collect(t, scraper.TweetSearch(context.Background(), "golang", 10))
+	if len(errs) != 1 || !errors.Is(errs[0], sns.ErrInvalidQuery) {
+		t.Fatalf("errors %v, want ErrInvalidQuery", errs)
+	}
+	if n := srv.Requests(twittertest.EndpointSearch); n != 0 {
+		t.Fatalf("%d search requests, want none", n)
+	}
+
+	ids, errs := collectUsers(t, scraper.UserSearch(context.Background(), "alice", 10))
+	if len(ids) == 0 || len(errs) > 0 {
+		t.Fatalf("users %v, errors %v", ids, errs)
+	}
+	p := srv.Params(twittertest.EndpointSearch)[0]
+	if p.Get("result_filter") != "user" || p.Get("tweet_search_mode") != "" {
+		t.Fatalf("result_filter=%q tweet_search_mode=%q, want the users vertical", p.Get("result_filter"), p.Get("tweet_search_mode"))
+	}
+}
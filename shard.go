@@ -0,0 +1,230 @@
+package sns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ShardPolicy splits a search over a long date range into time shards that
+// are searched concurrently, since search pagination degrades and stops
+// early on wide windows. Set it on Config.Sharding. Sharded searches save no
+// Checkpoint and cannot be resumed.
+type ShardPolicy struct {
+	// Size of the initial shards, a day by default.
+	Size time.Duration
+	// MinSize below which busy shards are not split any more, an hour by
+	// default.
+	MinSize time.Duration
+	// MaxPerShard results taken from a single shard search before the shard
+	// counts as busy: the part of its window not reached yet is split in two
+	// and searched again. A busy shard that cannot be split any more ends
+	// with an ErrShardTruncated result. 1000 by default.
+	MaxPerShard int
+	// Concurrency shards searched at once, 4 by default.
+	Concurrency int
+}
+
+// DefaultShardPolicy day shards split down to hours, 4 at a time.
+func DefaultShardPolicy() ShardPolicy {
+	return ShardPolicy{
+		Size:        24 * time.Hour,
+		MinSize:     time.Hour,
+		MaxPerShard: 1000,
+		Concurrency: 4,
+	}
+}
+
+func (p *ShardPolicy) orDefault() ShardPolicy {
+	policy := DefaultShardPolicy()
+	if p == nil {
+		return policy
+	}
+	if p.Size > 0 {
+		policy.Size = p.Size
+	}
+	if p.MinSize > 0 {
+		policy.MinSize = p.MinSize
+	}
+	if p.MaxPerShard > 0 {
+		policy.MaxPerShard = p.MaxPerShard
+	}
+	if p.Concurrency > 0 {
+		policy.Concurrency = p.Concurrency
+	}
+	return policy
+}
+
+// shard is the window [since, until) of a sharded search.
+type shard struct {
+	since, until time.Time
+	done         bool
+	results      []*TweetResult
+}
+
+type shardResult struct {
+	shard   *shard
+	results []*TweetResult
+	// busy when the shard search was stopped by MaxPerShard
+	busy bool
+}
+
+// shardedSearch searches query over [since, until) shard by shard and emits
+// the tweets oldest first, without duplicates.
+func (c *TwitterScraper) shardedSearch(ctx context.Context, query string, since, until time.Time, maxTweets int) <-chan *TweetResult {
+	channel := make(chan *TweetResult)
+	go c.runShards(ctx, query, since, until, maxTweets, c.config.Sharding.orDefault(), channel)
+	return channel
+}
+
+func (c *TwitterScraper) runShards(ctx context.Context, query string, since, until time.Time, maxTweets int, policy ShardPolicy, channel chan *TweetResult) {
+	beginAt := time.Now()
+	defer close(channel)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// shards ordered by time, a shard is emitted once all earlier ones are
+	var shards []*shard
+	for start := since; start.Before(until); start = start.Add(policy.Size) {
+		end := start.Add(policy.Size)
+		if end.After(until) {
+			end = until
+		}
+		shards = append(shards, &shard{since: start, until: end})
+	}
+	pending := append([]*shard(nil), shards...)
+
+	finished := make(chan shardResult, policy.Concurrency)
+	seen := make(map[int]bool)
+	var running, tweetNum int
+	for len(pending) > 0 || running > 0 {
+		for running < policy.Concurrency && len(pending) > 0 {
+			s := pending[0]
+			pending = pending[1:]
+			running++
+			// a shard needs no more tweets than are left to emit
+			limit := policy.MaxPerShard
+			if left := maxTweets - tweetNum; left < limit {
+				limit = left
+			}
+			go func(s *shard, limit int) {
+				r := c.searchShard(ctx, query, s, limit)
+				r.busy = r.busy && limit == policy.MaxPerShard
+				finished <- r
+			}(s, limit)
+		}
+
+		r := <-finished
+		running--
+		s := r.shard
+		if r.busy {
+			var children []*shard
+			if s.until.Sub(s.since) > policy.MinSize {
+				children = splitShard(s, r.results, policy.MinSize)
+			}
+			if len(children) > 0 {
+				c.config.Logger.Debug(beginAt, fmt.Sprintf("Shard %s - %s is busy, splitting %s - %s",
+					s.since.Format(datetimeLayout), s.until.Format(datetimeLayout),
+					children[0].since.Format(datetimeLayout), children[len(children)-1].until.Format(datetimeLayout)))
+				shards = insertShards(shards, s, children)
+				pending = append(children, pending...)
+			} else {
+				r.results = append(r.results, &TweetResult{Error: newError(ErrShardTruncated, "search", "%s - %s: more than %d tweets",
+					s.since.Format(datetimeLayout), s.until.Format(datetimeLayout), policy.MaxPerShard)})
+			}
+		}
+		s.done, s.results = true, r.results
+
+		for len(shards) > 0 && shards[0].done {
+			for _, tweet := range orderResults(shards[0].results) {
+				if tweet.TwitterPost != nil {
+					if seen[tweet.Id] {
+						continue
+					}
+					seen[tweet.Id] = true
+				}
+				if tweetNum >= maxTweets || !sendResult(ctx, channel, tweet) {
+					return
+				}
+				if tweet.TwitterPost != nil {
+					tweetNum++
+				}
+			}
+			shards = shards[1:]
+		}
+	}
+}
+
+// searchShard collects up to limit results of the shard window.
+func (c *TwitterScraper) searchShard(ctx context.Context, query string, s *shard, limit int) shardResult {
+	paginationParams := c.searchParams(query, c.config.SearchTab, s.since, s.until)
+	params := paginationParams
+	params.Del("cursor")
+
+	channel := make(chan *TweetResult)
+	go c.iteratorApiData(ctx, c.endpoints.Search+"?", params, paginationParams, "", limit, APIStandart, channel, parseTimeline, nil)
+
+	r := shardResult{shard: s}
+	var posts int
+	for tweet := range channel {
+		r.results = append(r.results, tweet)
+		if tweet.TwitterPost != nil {
+			posts++
+		}
+	}
+	r.busy = posts >= limit
+	return r
+}
+
+// splitShard shrinks a busy shard to the part its results cover and returns
+// the shards covering the rest of its window, nil when the results did not
+// get past its start.
+func splitShard(s *shard, results []*TweetResult, minSize time.Duration) []*shard {
+	var oldest time.Time
+	for _, r := range results {
+		if r.TwitterPost != nil && r.Date != nil && (oldest.IsZero() || r.Date.Before(oldest)) {
+			oldest = *r.Date
+		}
+	}
+	if oldest.IsZero() || !oldest.After(s.since) {
+		return nil
+	}
+
+	// tweets of the oldest second may be missing, the overlap is de-duplicated
+	rest := &shard{since: s.since, until: oldest.Add(time.Second)}
+	if rest.until.After(s.until) {
+		rest.until = s.until
+	}
+	s.since = oldest
+	if rest.until.Sub(rest.since) <= minSize {
+		return []*shard{rest}
+	}
+	mid := rest.since.Add(rest.until.Sub(rest.since) / 2).Truncate(time.Second)
+	return []*shard{{since: rest.since, until: mid}, {since: mid, until: rest.until}}
+}
+
+// insertShards inserts children right before s.
+func insertShards(shards []*shard, s *shard, children []*shard) []*shard {
+	merged := make([]*shard, 0, len(shards)+len(children))
+	for _, other := range shards {
+		if other == s {
+			merged = append(merged, children...)
+		}
+		merged = append(merged, other)
+	}
+	return merged
+}
+
+// orderResults sorts the tweets oldest first by their ID, errors last.
+func orderResults(results []*TweetResult) []*TweetResult {
+	ordered := append([]*TweetResult(nil), results...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i].TwitterPost, ordered[j].TwitterPost
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.Id < b.Id
+	})
+	return ordered
+}
@@ -0,0 +1,62 @@
+package sns_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/twittertest"
+)
+
+func TestShardedSearchTruncated(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{
+		Users:  fakeUsers,
+		Tweets: fakeTweets(10),
+	})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{
+		Date:     sns.DateRange{Since: "2021-01-01", Until: "2021-01-01"},
+		Sharding: &sns.ShardPolicy{Size: 24 * time.Hour, MinSize: 24 * time.Hour, MaxPerShard: 3},
+	})
+
+	var ids []int
+	var truncated error
+	for r := range scraper.TweetSearch(context.Background(), "from:alice", 100) {
+		switch {
+		case r.TwitterPost != nil:
+			ids = append(ids, r.Id)
+		case r.Error != nil:
+			truncated = r.Error
+		}
+	}
+	if len(ids) != 3 {
+		t.Errorf("got %d tweets %v, want the 3 of MaxPerShard", len(ids), ids)
+	}
+	if !errors.Is(truncated, sns.ErrShardTruncated) {
+		t.Errorf("error %v, want ErrShardTruncated", truncated)
+	}
+}
+
+func TestShardedSearchBudget(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{
+		Users:    fakeUsers,
+		Tweets:   fakeTweets(10),
+		PageSize: 2,
+	})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{
+		Date:     sns.DateRange{Since: "2021-01-01", Until: "2021-01-01"},
+		Sharding: &sns.ShardPolicy{Size: 24 * time.Hour, MaxPerShard: 1000},
+	})
+
+	ids, errs := collect(t, scraper.TweetSearch(context.Background(), "from:alice", 3))
+	if len(ids) != 3 || len(errs) > 0 {
+		t.Fatalf("got tweets %v and errors %v, want 3 tweets", ids, errs)
+	}
+	// 2 pages of 2 tweets cover the 3 asked for, not the 5 pages of the shard
+	if n := srv.Requests(twittertest.EndpointSearch); n != 2 {
+		t.Fatalf("%d search requests, want 2", n)
+	}
+}
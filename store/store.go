@@ -0,0 +1,157 @@
+// Package store persists scraper state such as guest tokens, cookies and
+// pagination checkpoints between runs.
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNotFound the key does not exist or has expired.
+var ErrNotFound = errors.New("store: not found")
+
+// Store is a key/value store with expiry. FileStore is the default
+// implementation; Redis-like backends implement it with GET/SET EX/DEL.
+type Store interface {
+	// Get returns the value of key or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key, ttl zero meaning no expiry.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key, deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+type fileEntry struct {
+	// Value holds JSON values as is, so the file stays readable.
+	Value json.RawMessage `json:"value,omitempty"`
+	// Bytes holds any other value, base64 encoded.
+	Bytes     string     `json:"bytes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// FileStore keeps every key in a single JSON file. It is safe for concurrent
+// use within a process; the file is rewritten atomically on every change.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	now  func() time.Time
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path, now: time.Now}
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[key]
+	if !ok || s.expired(entry) {
+		return nil, ErrNotFound
+	}
+	if entry.Value != nil {
+		return entry.Value, nil
+	}
+	return base64.StdEncoding.DecodeString(entry.Bytes)
+}
+
+func (s *FileStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	var entry fileEntry
+	if json.Valid(value) {
+		entry.Value = append(json.RawMessage(nil), value...)
+	} else {
+		entry.Bytes = base64.StdEncoding.EncodeToString(value)
+	}
+	if ttl > 0 {
+		expires := s.now().Add(ttl)
+		entry.ExpiresAt = &expires
+	}
+	entries[key] = entry
+	return s.write(entries)
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[key]; !ok {
+		return nil
+	}
+	delete(entries, key)
+	return s.write(entries)
+}
+
+func (s *FileStore) expired(entry fileEntry) bool {
+	return entry.ExpiresAt != nil && !s.now().Before(*entry.ExpiresAt)
+}
+
+func (s *FileStore) read() (map[string]fileEntry, error) {
+	entries := make(map[string]fileEntry)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("store: %s: %w", s.path, err)
+	}
+	return entries, nil
+}
+
+// write drops expired entries and replaces the file through a rename.
+func (s *FileStore) write(entries map[string]fileEntry) error {
+	for key, entry := range entries {
+		if s.expired(entry) {
+			delete(entries, key)
+		}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
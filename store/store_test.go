@@ -0,0 +1,149 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state", "store.json")
+	s := NewFileStore(path)
+
+	tests := []struct {
+		name  string
+		value []byte
+	}{
+		{name: "json", value: []byte(`[{"value":"1500000000000000001"}]`)},
+		{name: "bytes", value: []byte{0, 1, 2, 0xff}},
+		{name: "text", value: []byte("DAABCgAB")},
+	}
+	for _, tt := range tests {
+		if err := s.Set(ctx, tt.name, tt.value, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a new store reads what the previous one wrote
+	reopened := NewFileStore(path)
+	for _, tt := range tests {
+		got, err := reopened.Get(ctx, tt.name)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// JSON values come back re-indented by the file
+		if json.Valid(got) {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, got); err != nil {
+				t.Fatal(err)
+			}
+			got = compact.Bytes()
+		}
+		if string(got) != string(tt.value) {
+			t.Fatalf("%s: got %q, want %q", tt.name, got, tt.value)
+		}
+	}
+
+	// JSON values stay readable in the file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"value": "1500000000000000001"`) {
+		t.Fatalf("file %s, want the JSON value as is", data)
+	}
+
+	if err := reopened.Delete(ctx, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Delete(ctx, "json"); err != nil {
+		t.Fatalf("deleting a missing key: %v", err)
+	}
+	if _, err := s.Get(ctx, "json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("error %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.json")
+	now := time.Unix(1600000000, 0)
+	s := NewFileStore(path)
+	s.now = func() time.Time { return now }
+
+	if err := s.Set(ctx, "token", []byte(`"gt"`), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "cookies", []byte(`[]`), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get(ctx, "token"); err != nil || string(got) != `"gt"` {
+		t.Fatalf("got %q, %v before the expiry", got, err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := s.Get(ctx, "token"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("error %v, want the expired key not found", err)
+	}
+	if _, err := s.Get(ctx, "cookies"); err != nil {
+		t.Fatalf("key without ttl: %v", err)
+	}
+
+	// the next write drops expired keys from the file
+	if err := s.Set(ctx, "other", []byte(`1`), 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"token"`) {
+		t.Fatalf("file %s still holds the expired key", data)
+	}
+}
+
+func TestFileStoreFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// a missing or empty file is an empty store
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(dir, "missing.json"), empty} {
+		if _, err := NewFileStore(path).Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: error %v, want ErrNotFound", path, err)
+		}
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(corrupt).Get(ctx, "key"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("error %v, want the file reported corrupt", err)
+	}
+	if err := NewFileStore(corrupt).Set(ctx, "key", []byte("1"), 0); err == nil {
+		t.Fatal("a corrupt file was overwritten")
+	}
+
+	// no temporary files are left next to the store
+	s := NewFileStore(filepath.Join(dir, "store.json"))
+	for i := 0; i < 3; i++ {
+		if err := s.Set(ctx, "key", []byte("1"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "store.json.*"))
+	if len(matches) > 0 {
+		t.Fatalf("temporary files left: %v", matches)
+	}
+}
@@ -0,0 +1,142 @@
+package sns
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hinha/go-social-network/utils"
+)
+
+// TweetThread scrapes the conversation of tweetID through the TweetDetail
+// endpoint: the focal tweet, its ancestors and every reply reachable through
+// the bottom, showMoreThreadsPrompt and per-thread "show more" cursors.
+// Each reply links to its parent through InReplyToTweetId and to the
+// conversation root through ConversationId.
+func (c *TwitterScraper) TweetThread(ctx context.Context, tweetID int, maxTweets int) <-chan *TweetResult {
+	baseUrl := fmt.Sprintf("%s/i/web/status/%d", c.endpoints.Web, tweetID)
+	if err := c.ensureGuestToken(ctx, baseUrl); err != nil {
+		return errorChannel(err)
+	}
+
+	// the other variables come from the operation, see Endpoints
+	variables := url.Values{}
+	variables.Add("focalTweetId", strconv.Itoa(tweetID))
+
+	paginationVariables := url.Values{}
+	for k, v := range variables {
+		paginationVariables[k] = v
+	}
+	paginationVariables.Add("referrer", "tweet")
+
+	channel := make(chan *TweetResult)
+	go c.iteratorThread(ctx, c.endpoints.graphqlURL(OpTweetDetail)+"?", variables, paginationVariables, maxTweets, channel)
+	return channel
+}
+
+// iteratorThread walks every cursor of a conversation breadth first. Pages of
+// a conversation overlap, so tweets are de-duplicated by ID.
+func (c *TwitterScraper) iteratorThread(ctx context.Context, endpoint string, params url.Values, paginationParams url.Values, maxTweet int, channel chan *TweetResult) {
+	beginAt := time.Now()
+	defer close(channel)
+
+	seenTweets := make(map[int]bool)
+	seenCursors := make(map[string]bool)
+	queue := []string{""}
+	var tweetNum int
+
+	for len(queue) > 0 {
+		cursor := queue[0]
+		queue = queue[1:]
+
+		reqParams := params
+		if cursor != "" {
+			reqParams = url.Values{}
+			for k, v := range paginationParams {
+				reqParams[k] = v
+			}
+			reqParams.Set("cursor", cursor)
+		}
+
+		c.config.Logger.Info(beginAt, "Retrieving thread page ", cursor)
+		obj, err := c.get_api_data(ctx, endpoint, reqParams, APIGraphql)
+		if err != nil {
+			sendResult(ctx, channel, &TweetResult{Error: err})
+			return
+		}
+
+		instructions := obj.Data.ThreadedConversationWithInjections.Instructions
+		for _, tweet := range parseThread(instructions) {
+			if tweet.TwitterPost != nil {
+				if seenTweets[tweet.Id] {
+					continue
+				}
+				seenTweets[tweet.Id] = true
+			}
+			if tweetNum >= maxTweet || !sendResult(ctx, channel, tweet) {
+				return
+			}
+			if tweet.TwitterPost != nil {
+				tweetNum++
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		cursors, err := scanThreadCursors(instructions)
+		if err != nil {
+			sendResult(ctx, channel, &TweetResult{Error: err})
+			return
+		}
+		for _, next := range cursors {
+			if next == "" || seenCursors[next] {
+				continue
+			}
+			seenCursors[next] = true
+			queue = append(queue, next)
+		}
+	}
+}
+
+// scanThreadCursors returns every cursor of a TweetDetail page: top cursors
+// load more ancestors, bottom and showMoreThreadsPrompt cursors load more
+// replies, and cursors nested in conversation modules expand a single thread.
+func scanThreadCursors(instructions []TweetInstructions) (cursors []string, err error) {
+	var entryID string
+	defer func() {
+		if r := recover(); r != nil {
+			err = newError(ErrSchemaChanged, "thread", "entry %s: %v", entryID, r)
+		}
+	}()
+
+	for _, instruction := range instructions {
+		if instruction.Type != "TimelineAddEntries" {
+			continue
+		}
+
+		for _, obj := range instruction.Entries {
+			entry := utils.Dict(obj)
+			entryID = entry.M("entryId").String()
+
+			if strings.HasPrefix(entryID, "cursor-") {
+				value, _ := graphqlCursor(entry.M("content").MapInterface())
+				cursors = append(cursors, value)
+			} else if strings.HasPrefix(entryID, "conversationthread-") {
+				items, _ := entry.M("content").M("items").Interface().([]interface{})
+				for _, item := range items {
+					item := utils.Dict(item)
+					if itemID, _ := item.M("entryId").Interface().(string); !strings.Contains(itemID, "-cursor-") {
+						continue
+					}
+					value, _ := graphqlCursor(item.M("item").M("itemContent").MapInterface())
+					cursors = append(cursors, value)
+				}
+			}
+		}
+	}
+	return cursors, nil
+}
@@ -0,0 +1,209 @@
+package sns
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// threadTweet is the itemContent of a TweetDetail tweet replying to parent,
+// 0 for the root of conversation 1.
+func threadTweet(id, parent int) map[string]interface{} {
+	legacy := map[string]interface{}{
+		"id_str":              strconv.Itoa(id),
+		"conversation_id_str": "1",
+		"created_at":          "Fri Jan 01 10:00:00 +0000 2021",
+		"full_text":           "reply",
+		"user_id_str":         "7",
+		"entities":            map[string]interface{}{},
+	}
+	if parent != 0 {
+		legacy["in_reply_to_status_id_str"] = strconv.Itoa(parent)
+		legacy["in_reply_to_user_id_str"] = "7"
+	}
+	return map[string]interface{}{
+		"itemType": "TimelineTweet",
+		"tweet_results": map[string]interface{}{"result": map[string]interface{}{
+			"__typename": "Tweet",
+			"rest_id":    strconv.Itoa(id),
+			"core": map[string]interface{}{"user_results": map[string]interface{}{"result": map[string]interface{}{
+				"rest_id": "7",
+				"legacy":  map[string]interface{}{"id_str": "7", "screen_name": "alice"},
+			}}},
+			"legacy": legacy,
+		}},
+	}
+}
+
+func threadTombstone() map[string]interface{} {
+	return map[string]interface{}{
+		"itemType":      "TimelineTweet",
+		"tweet_results": map[string]interface{}{"result": map[string]interface{}{"__typename": "TweetTombstone"}},
+	}
+}
+
+func threadCursor(value string) map[string]interface{} {
+	return map[string]interface{}{"itemType": "TimelineTimelineCursor", "value": value}
+}
+
+func tweetEntry(id, parent int) map[string]interface{} {
+	return map[string]interface{}{
+		"entryId": "tweet-" + strconv.Itoa(id),
+		"content": map[string]interface{}{"entryType": "TimelineTimelineItem", "itemContent": threadTweet(id, parent)},
+	}
+}
+
+func cursorEntry(name, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"entryId": "cursor-" + name,
+		"content": map[string]interface{}{"entryType": "TimelineTimelineCursor", "value": value},
+	}
+}
+
+// moduleEntry is a conversation module of items given as pairs of entry ID
+// suffix, e.g. "tweet-3" or "cursor-showmore-1", and item content.
+func moduleEntry(id int, items ...interface{}) map[string]interface{} {
+	prefix := "conversationthread-" + strconv.Itoa(id)
+	var list []interface{}
+	for i := 0; i < len(items); i += 2 {
+		list = append(list, map[string]interface{}{
+			"entryId": prefix + "-" + items[i].(string),
+			"item":    map[string]interface{}{"itemContent": items[i+1]},
+		})
+	}
+	return map[string]interface{}{
+		"entryId": prefix,
+		"content": map[string]interface{}{"entryType": "TimelineTimelineModule", "items": list},
+	}
+}
+
+// threadInstructions decodes entries the way a TweetDetail response is.
+func threadInstructions(t *testing.T, entries ...interface{}) []TweetInstructions {
+	t.Helper()
+	data, err := json.Marshal([]interface{}{
+		map[string]interface{}{"type": "TimelineClearCache"},
+		map[string]interface{}{"type": "TimelineAddEntries", "entries": entries},
+		map[string]interface{}{"type": "TimelineTerminateTimeline", "direction": "Top"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var instructions []TweetInstructions
+	if err := json.Unmarshal(data, &instructions); err != nil {
+		t.Fatal(err)
+	}
+	return instructions
+}
+
+func TestParseThread(t *testing.T) {
+	instructions := threadInstructions(t,
+		tweetEntry(1, 0),
+		tweetEntry(2, 1),
+		moduleEntry(3,
+			"tweet-3", threadTweet(3, 2),
+			"tweet-4", threadTweet(4, 3),
+			"tweet-5", threadTombstone(),
+			"cursor-showmore-9", threadCursor("more-3"),
+		),
+		moduleEntry(6, "tweet-6", threadTweet(6, 1)),
+		cursorEntry("bottom-1", "bottom"),
+	)
+
+	var ids, parents []int
+	for _, tweet := range parseThread(instructions) {
+		if tweet.Error != nil {
+			t.Fatal(tweet.Error)
+		}
+		ids = append(ids, tweet.Id)
+		parents = append(parents, tweet.InReplyToTweetId)
+		if tweet.ConversationId != 1 {
+			t.Errorf("tweet %d in conversation %d, want 1", tweet.Id, tweet.ConversationId)
+		}
+	}
+	// the tombstone and the cursors yield no tweet
+	if want := []int{1, 2, 3, 4, 6}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("tweets %v, want %v", ids, want)
+	}
+	if want := []int{0, 1, 2, 3, 1}; !reflect.DeepEqual(parents, want) {
+		t.Fatalf("parents %v, want %v", parents, want)
+	}
+}
+
+func TestParseThreadSchemaChanged(t *testing.T) {
+	broken := threadTweet(2, 1)
+	broken["tweet_results"].(map[string]interface{})["result"].(map[string]interface{})["__typename"] = "TweetUnknown"
+	instructions := threadInstructions(t, tweetEntry(1, 0), moduleEntry(2, "tweet-2", broken))
+
+	tweets := parseThread(instructions)
+	if len(tweets) != 2 || tweets[0].Id != 1 || !errors.Is(tweets[1].Error, ErrSchemaChanged) {
+		t.Fatalf("tweets %+v, want the root and a schema error", tweets)
+	}
+}
+
+func TestScanThreadCursors(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []interface{}
+		cursors []string
+	}{
+		{
+			name:    "no cursors",
+			entries: []interface{}{tweetEntry(1, 0)},
+		},
+		{
+			name: "top and bottom",
+			entries: []interface{}{
+				cursorEntry("top-1", "top"),
+				tweetEntry(1, 0),
+				cursorEntry("bottom-1", "bottom"),
+			},
+			cursors: []string{"top", "bottom"},
+		},
+		{
+			name: "show more threads prompt",
+			entries: []interface{}{
+				tweetEntry(1, 0),
+				map[string]interface{}{
+					"entryId": "cursor-showmorethreadsprompt-1",
+					"content": map[string]interface{}{
+						"entryType":   "TimelineTimelineItem",
+						"itemContent": map[string]interface{}{"itemType": "TimelineTimelineCursor", "value": "prompt", "cursorType": "ShowMoreThreadsPrompt"},
+					},
+				},
+			},
+			cursors: []string{"prompt"},
+		},
+		{
+			name: "per thread show more",
+			entries: []interface{}{
+				moduleEntry(2, "tweet-2", threadTweet(2, 1), "cursor-showmore-1", threadCursor("more-2")),
+				moduleEntry(3, "tweet-3", threadTweet(3, 1)),
+				moduleEntry(4, "tweet-4", threadTweet(4, 1), "cursor-showmore-2", threadCursor("more-4")),
+			},
+			cursors: []string{"more-2", "more-4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursors, err := scanThreadCursors(threadInstructions(t, tt.entries...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cursors, tt.cursors) {
+				t.Fatalf("cursors %q, want %q", cursors, tt.cursors)
+			}
+		})
+	}
+}
+
+func TestScanThreadCursorsSchemaChanged(t *testing.T) {
+	entry := cursorEntry("bottom-1", "bottom")
+	entry["content"] = map[string]interface{}{"entryType": "TimelineTimelineItem", "itemContent": "gone"}
+
+	_, err := scanThreadCursors(threadInstructions(t, entry))
+	if !errors.Is(err, ErrSchemaChanged) {
+		t.Fatalf("error %v, want ErrSchemaChanged", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -17,9 +18,10 @@ var (
 	regexLink, _  = regexp.Compile(`href=[\'"]?([^\'" >]+)`)
 	regexLabel, _ = regexp.Compile(`>([^<]*)<`)
 	regexExt      = regexp.MustCompile("(\\.[^.]+)$") // extension
+	regexPollCard = regexp.MustCompile(`^poll[2-4]choice_`)
 )
 
-type parseTweets func(timeline twitterResponse, gotPinned *bool, dateRange DateRange) []*entities.TwitterPost
+type parseTweets func(timeline twitterResponse, gotPinned *bool, since, until time.Time) []*TweetResult
 
 func checkEntries(instruction TweetInstructions) []interface{} {
 	var entries interface{}
@@ -34,7 +36,8 @@ func checkEntries(instruction TweetInstructions) []interface{} {
 		return nil
 	}
 
-	return entries.([]interface{})
+	list, _ := entries.([]interface{})
+	return list
 }
 
 func getTweetId(tweet TweetRaw) int {
@@ -66,7 +69,33 @@ func renderTextWithUrls(text string, urls []TweetUrls) []string {
 	}
 }
 
-func tweetCard(card *TweetRawCard, tweetID int, apiType VersionAPI) entities.TwitterCard {
+// cardBindings returns the binding values of card by key, GraphQL cards list
+// them as key and value pairs.
+func cardBindings(card *TweetRawCard, apiType VersionAPI) map[string]interface{} {
+	if apiType != APIGraphql {
+		return card.BindingValues
+	}
+	bindings := make(map[string]interface{}, len(card.Legacy.BindingValues))
+	for _, v := range card.Legacy.BindingValues {
+		pair, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if key, ok := pair["key"].(string); ok {
+			bindings[key] = pair["value"]
+		}
+	}
+	return bindings
+}
+
+// tweetCard parses the card of a tweet, the poll cards into a poll. Cards
+// without a typed variant are kept as TwitterCard.Generic, cards without a
+// name are unsupported.
+func tweetCard(card *TweetRawCard, tweetID int, apiType VersionAPI) (mapCard entities.TwitterCard, poll *entities.TwitterPoll, err error) {
+	defer recoverSchema("card", strconv.Itoa(tweetID), &err)
+	if card == nil {
+		return mapCard, nil, nil
+	}
 	userRefs := make(map[string]entities.TwitterUser)
 	if apiType == APIStandart {
 		for key, _ := range card.Users {
@@ -86,26 +115,37 @@ func tweetCard(card *TweetRawCard, tweetID int, apiType VersionAPI) entities.Twi
 			}
 		}
 	}
-	mapCard := entities.TwitterCard{}
 	bindingValues := make(map[string]interface{})
-	for key, value := range card.BindingValues {
-		binding := value.(map[string]interface{})
+	for key, value := range cardBindings(card, apiType) {
+		binding, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
 		if _, ok := binding["type"]; !ok {
 			continue
 		}
 		if binding["type"] == "STRING" {
-			bindingValues[key] = binding["string_value"]
+			str, _ := binding["string_value"].(string)
+			bindingValues[key] = str
 			if strings.HasSuffix(key, "_datetime_utc") {
-				bindingValues[key], _ = time.Parse(time.RFC3339, bindingValues[key].(string))
+				bindingValues[key], _ = time.Parse(time.RFC3339, str)
 			}
 		} else if binding["type"] == "IMAGE" {
-			bindingValues[key] = binding["image_value"].(map[string]interface{})["url"]
+			image, _ := binding["image_value"].(map[string]interface{})
+			bindingValues[key] = image["url"]
 		} else if binding["type"] == "BOOLEAN" {
 			bindingValues[key] = binding["boolean_value"]
 		} else if binding["type"] == "IMAGE_COLOR" {
-			// skip this section
+			color, _ := binding["image_color_value"].(map[string]interface{})
+			bindingValues[key] = imageColors(color)
 		} else if binding["type"] == "USER" {
-			bindingValues[key] = userRefs[binding["user_value"].(map[string]interface{})["id_str"].(string)]
+			user, _ := binding["user_value"].(map[string]interface{})
+			id, _ := user["id_str"].(string)
+			ref, ok := userRefs[id]
+			if !ok {
+				ref.Id, _ = strconv.Atoi(id)
+			}
+			bindingValues[key] = ref
 		} else {
 			log.Printf("WARN: Unsupported card value type on %s in tweet %d:%v", key, tweetID, binding["type"])
 		}
@@ -118,27 +158,210 @@ func tweetCard(card *TweetRawCard, tweetID int, apiType VersionAPI) entities.Twi
 		cardName = card.Legacy.Name
 	}
 
-	if cardName == "summary" || cardName == "summary_large_image" || cardName == "app" || cardName == "direct_store_link_app" {
-		if cardName == "app" || cardName == "direct_store_link_app" {
-			mapCard.AppCard = bindingValues
-		} else {
-			mapCard.SummaryCard = bindingValues
-		}
-		//} else if strings.HasPrefix(card.Name, "poll2choice_") || strings.HasPrefix(card.Name, "poll3choice_") || strings.HasPrefix(card.Name, "poll4choice_") {
-		//} else if card.Name == "745291183405076480:broadcast" || card.Name == "3691233323:periscope_broadcast" {
-		//} else if card.Name == "appplayer" {
+	if cardName == "summary" || cardName == "summary_large_image" {
+		mapCard.SummaryCard = &entities.TwitterSummaryCard{
+			Title:           bindingString(bindingValues, "title"),
+			Url:             bindingString(bindingValues, "card_url"),
+			Domain:          bindingString(bindingValues, "domain", "vanity_url"),
+			Description:     bindingString(bindingValues, "description"),
+			ThumbnailUrl:    bindingString(bindingValues, "thumbnail_image_original", "summary_photo_image_original", "thumbnail_image_large", "thumbnail_image"),
+			ThumbnailColors: bindingColors(bindingValues, "thumbnail_image_color", "summary_photo_image_color"),
+			LargeImage:      cardName == "summary_large_image",
+			SiteUser:        bindingUser(bindingValues, "site"),
+			CreatorUser:     bindingUser(bindingValues, "creator"),
+		}
+	} else if cardName == "app" || cardName == "direct_store_link_app" {
+		mapCard.AppCard = &entities.TwitterAppCard{
+			Title:           bindingString(bindingValues, "title"),
+			Url:             bindingString(bindingValues, "card_url"),
+			Description:     bindingString(bindingValues, "description"),
+			ThumbnailUrl:    bindingString(bindingValues, "thumbnail_image_original", "thumbnail_image_large", "thumbnail_image"),
+			ThumbnailColors: bindingColors(bindingValues, "thumbnail_image_color"),
+			AppName:         bindingString(bindingValues, "app_name"),
+			AppCategory:     bindingString(bindingValues, "app_category"),
+			IPhoneId:        bindingString(bindingValues, "app_id_iphone"),
+			IPadId:          bindingString(bindingValues, "app_id_ipad"),
+			GooglePlayId:    bindingString(bindingValues, "app_id_googleplay"),
+			Rating:          bindingFloat(bindingValues, "app_star_rating"),
+			Ratings:         bindingInt(bindingValues, "app_num_ratings"),
+			Price:           bindingFloat(bindingValues, "app_price_amount"),
+			Currency:        bindingString(bindingValues, "app_price_currency"),
+			Free:            bindingBool(bindingValues, "app_is_free"),
+			SiteUser:        bindingUser(bindingValues, "site"),
+		}
+	} else if regexPollCard.MatchString(cardName) {
+		return mapCard, parsePoll(bindingValues), nil
 	} else if cardName == "player" {
-		mapCard.PlayerCard = bindingValues
-	} else if cardName == "3337203208:newsletter_publication" {
-		panic("3337203208:newsletter_publication")
-	} else if cardName == "3337203208:newsletter_issue" {
-		panic("3337203208:newsletter_issue")
+		mapCard.PlayerCard = &entities.TwitterPlayerCard{
+			Title:             bindingString(bindingValues, "title"),
+			Url:               bindingString(bindingValues, "card_url"),
+			Description:       bindingString(bindingValues, "description"),
+			ThumbnailUrl:      bindingString(bindingValues, "player_image_original", "player_image_large", "player_image"),
+			ThumbnailColors:   bindingColors(bindingValues, "player_image_color"),
+			PlayerUrl:         bindingString(bindingValues, "player_url"),
+			StreamUrl:         bindingString(bindingValues, "player_stream_url"),
+			StreamContentType: bindingString(bindingValues, "player_stream_content_type"),
+			Width:             bindingInt(bindingValues, "player_width"),
+			Height:            bindingInt(bindingValues, "player_height"),
+			SiteUser:          bindingUser(bindingValues, "site"),
+		}
+	} else if cardName == "3337203208:newsletter_publication" || cardName == "3337203208:newsletter_issue" {
+		mapCard.Newsletter = &entities.TwitterNewsletterCard{
+			Title:            bindingString(bindingValues, "newsletter_title"),
+			Description:      bindingString(bindingValues, "newsletter_description"),
+			Url:              bindingString(bindingValues, "card_url"),
+			ImageUrl:         bindingString(bindingValues, "newsletter_image_original", "issue_image_original"),
+			IssueTitle:       bindingString(bindingValues, "issue_title"),
+			IssueDescription: bindingString(bindingValues, "issue_description"),
+			IssueNumber:      bindingInt(bindingValues, "issue_number"),
+		}
 	} else if cardName == "amplify" {
-		panic("amplify")
+		mapCard.Amplify = &entities.TwitterAmplifyCard{
+			Url:          bindingString(bindingValues, "player_url"),
+			ThumbnailUrl: bindingString(bindingValues, "player_image_original", "player_image_large", "player_image"),
+			Duration:     bindingFloat(bindingValues, "content_duration_seconds"),
+			Variants:     playerVariants(bindingValues),
+		}
 	} else if cardName == "appplayer" {
-		panic("appplayer")
+		mapCard.AppPlayer = &entities.TwitterAppPlayerCard{
+			Title:        bindingString(bindingValues, "title"),
+			AppCategory:  bindingString(bindingValues, "app_category"),
+			AppRating:    bindingFloat(bindingValues, "app_star_rating"),
+			AppRatings:   bindingInt(bindingValues, "app_num_ratings"),
+			Url:          bindingString(bindingValues, "card_url", "player_url"),
+			ThumbnailUrl: bindingString(bindingValues, "player_image_original", "player_image_large", "player_image"),
+			Variants:     playerVariants(bindingValues),
+		}
+	} else if cardName == "745291183405076480:broadcast" || cardName == "3691233323:periscope_broadcast" {
+		broadcaster := bindingUser(bindingValues, "broadcaster")
+		if username := bindingString(bindingValues, "broadcaster_username"); broadcaster == nil && username != "" {
+			broadcaster = &entities.TwitterUser{
+				Username:    username,
+				DisplayName: bindingString(bindingValues, "broadcaster_display_name"),
+			}
+		}
+		mapCard.Broadcast = &entities.TwitterBroadcastCard{
+			Id:           bindingString(bindingValues, "broadcast_id", "id"),
+			Url:          bindingString(bindingValues, "broadcast_url", "url"),
+			Title:        bindingString(bindingValues, "broadcast_title", "title"),
+			State:        bindingString(bindingValues, "broadcast_state", "state"),
+			ThumbnailUrl: bindingString(bindingValues, "broadcast_thumbnail_original", "full_size_thumbnail_url", "broadcast_thumbnail"),
+			Broadcaster:  broadcaster,
+		}
+	} else if cardName != "" {
+		mapCard.Generic = &entities.TwitterGenericCard{Name: cardName, BindingValues: bindingValues}
+	} else {
+		return mapCard, nil, newError(ErrUnsupportedCard, "card", "tweet %d: card without a name", tweetID)
+	}
+	return mapCard, nil, nil
+}
+
+// bindingString returns the first string binding value of keys.
+func bindingString(values map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := values[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// bindingInt returns a number binding value, cards send numbers as strings,
+// at times with thousands separators.
+func bindingInt(values map[string]interface{}, key string) int {
+	n, _ := strconv.Atoi(strings.ReplaceAll(bindingString(values, key), ",", ""))
+	return n
+}
+
+func bindingFloat(values map[string]interface{}, key string) float64 {
+	f, _ := strconv.ParseFloat(bindingString(values, key), 64)
+	return f
+}
+
+// bindingBool returns a boolean binding value, sent as a BOOLEAN or a string.
+func bindingBool(values map[string]interface{}, key string) bool {
+	if b, ok := values[key].(bool); ok {
+		return b
+	}
+	return bindingString(values, key) == "true"
+}
+
+// bindingUser returns a USER binding value, nil when the user is unknown.
+func bindingUser(values map[string]interface{}, key string) *entities.TwitterUser {
+	if user, ok := values[key].(entities.TwitterUser); ok && (user.Id != 0 || user.Username != "") {
+		return &user
+	}
+	return nil
+}
+
+// bindingColors returns the first IMAGE_COLOR binding value of keys.
+func bindingColors(values map[string]interface{}, keys ...string) []entities.TwitterImageColor {
+	for _, key := range keys {
+		if colors, ok := values[key].([]entities.TwitterImageColor); ok && len(colors) > 0 {
+			return colors
+		}
+	}
+	return nil
+}
+
+// imageColors reads the palette of an image_color_value.
+func imageColors(value map[string]interface{}) []entities.TwitterImageColor {
+	palette, _ := value["palette"].([]interface{})
+	colors := make([]entities.TwitterImageColor, 0, len(palette))
+	for _, p := range palette {
+		entry, _ := p.(map[string]interface{})
+		rgb, _ := entry["rgb"].(map[string]interface{})
+		red, _ := rgb["red"].(float64)
+		green, _ := rgb["green"].(float64)
+		blue, _ := rgb["blue"].(float64)
+		percentage, _ := entry["percentage"].(float64)
+		colors = append(colors, entities.TwitterImageColor{
+			Red:        int(red),
+			Green:      int(green),
+			Blue:       int(blue),
+			Percentage: percentage,
+		})
+	}
+	return colors
+}
+
+// playerVariants returns the video streams of a player card.
+func playerVariants(values map[string]interface{}) []entities.TwitterVideoVariant {
+	var variants []entities.TwitterVideoVariant
+	if stream := bindingString(values, "player_stream_url"); stream != "" {
+		variants = append(variants, entities.TwitterVideoVariant{
+			ContentType: bindingString(values, "player_stream_content_type"),
+			Url:         stream,
+		})
+	}
+	if vmap := bindingString(values, "amplify_url_vmap"); vmap != "" {
+		variants = append(variants, entities.TwitterVideoVariant{ContentType: "text/xml", Url: vmap})
 	}
-	return mapCard
+	return variants
+}
+
+// parsePoll reads the binding values of a poll card.
+func parsePoll(values map[string]interface{}) *entities.TwitterPoll {
+	poll := &entities.TwitterPoll{}
+	for i := 1; ; i++ {
+		label, ok := values[fmt.Sprintf("choice%d_label", i)].(string)
+		if !ok {
+			break
+		}
+		count := bindingInt(values, fmt.Sprintf("choice%d_count", i))
+		poll.Options = append(poll.Options, entities.TwitterPollOption{Label: label, Count: count})
+		poll.TotalVotes += count
+	}
+	if end, ok := values["end_datetime_utc"].(time.Time); ok && !end.IsZero() {
+		poll.EndDate = &end
+	}
+	if updated, ok := values["last_updated_datetime_utc"].(time.Time); ok && !updated.IsZero() {
+		poll.LastUpdated = &updated
+	}
+	poll.DurationMinutes = bindingInt(values, "duration_minutes")
+	poll.Final, _ = values["counts_are_final"].(bool)
+	poll.ImageUrl = bindingString(values, "image_original", "image_large", "image")
+	return poll
 }
 
 func makeTweet(tweet TweetRaw, user entities.TwitterUser, card entities.TwitterCard, posts ...interface{}) *entities.TwitterPost {
@@ -166,7 +389,7 @@ func makeTweet(tweet TweetRaw, user entities.TwitterUser, card entities.TwitterC
 		tw.Url = fmt.Sprintf("https://twitter.com/%s/status/%d", tw.User.Username, tw.Id)
 		cvId, err := strconv.Atoi(tweet.ConversationIDStr)
 		if err != nil {
-			tw.ConversationId = tweet.ConversationID
+			cvId = tweet.ConversationID
 		}
 		tw.ConversationId = cvId
 		if v := regexLink.FindStringSubmatch(tweet.Source); len(v) > 1 {
@@ -176,61 +399,13 @@ func makeTweet(tweet TweetRaw, user entities.TwitterUser, card entities.TwitterC
 			tw.SourceLabel = v[1]
 		}
 
-		// data media
 		for _, media := range tweet.ExtendedEntities.Media {
-			if media.Type == "photo" {
-				if strings.Contains(media.MediaURLHttps, "?format=") || strings.Contains(media.MediaURLHttps, "&format=") {
-					tw.Media.Photo.PreviewUrl = media.MediaURLHttps
-					tw.Media.Photo.FullUrl = media.MediaURLHttps
-				}
-				if !strings.Contains(media.MediaURLHttps, ".") {
-					continue
-				}
-				format := regexExt.FindString(media.MediaURLHttps)[1:]
-				baseUrl := regexExt.Split(media.MediaURLHttps, 1)[0] // url
-				if format != "jpg" && format != "png" {
-					continue
-				}
-				tw.Media.Photo.PreviewUrl = fmt.Sprintf("%s?format=%s&name=small", baseUrl, format)
-				tw.Media.Photo.FullUrl = fmt.Sprintf("%s?format=%s&name=large", baseUrl, format)
-			} else if media.Type == "video" || media.Type == "animated_gif" {
-				if media.Type == "video" {
-					for _, variant := range media.VideoInfo.Variants {
-						tw.Media.Video.Variants = append(tw.Media.Video.Variants, entities.TwitterVideoVariant{
-							ContentType: variant.ContentType,
-							Url:         variant.URL,
-							BitRate:     variant.Bitrate,
-						})
-					}
-					tw.Media.Video.ThumbnailUrl = media.MediaURLHttps
-					tw.Media.Video.Duration = float64(media.VideoInfo.DurationMillis / 1000)
-					if r, ok := media.Ext.MediaStats["r"]; ok {
-						if vok, ok := r.(map[string]interface{})["ok"]; ok {
-							if count, ok := vok.(map[string]interface{})["viewCount"]; ok {
-								switch v := count.(type) {
-								case string:
-									tw.Media.Video.Views, _ = strconv.Atoi(v)
-								case int:
-									tw.Media.Video.Views = count.(int)
-								}
-							}
-						}
-					}
-				} else if media.Type == "animated_gif" {
-					for _, variant := range media.VideoInfo.Variants {
-						tw.Media.Gif.Variants = append(tw.Media.Gif.Variants, entities.TwitterVideoVariant{
-							ContentType: variant.ContentType,
-							Url:         variant.URL,
-							BitRate:     variant.Bitrate,
-						})
-					}
-					tw.Media.Gif.ThumbnailUrl = media.MediaURLHttps
-				}
-			}
+			tw.Media = append(tw.Media, parseMedia(media))
 		}
 	}
 
 	tw.InReplyToTweetId, _ = strconv.Atoi(tweet.InReplyToStatusIDStr)
+	tw.InReplyToStatusIdStr = tweet.InReplyToStatusIDStr
 	inReplyToUserIdStr, _ := strconv.Atoi(tweet.InReplyToUserIdStr)
 	if inReplyToUserIdStr == tw.User.Id {
 		tw.InReplyToUser = tw.User
@@ -292,6 +467,9 @@ func makeTweet(tweet TweetRaw, user entities.TwitterUser, card entities.TwitterC
 	tw.User = user
 	tw.Card = card
 	for _, t := range posts {
+		if v, ok := utils.IsMapKey(t, "poll"); ok {
+			tw.Poll, _ = v.(*entities.TwitterPoll)
+		}
 		if v, ok := utils.IsMapKey(t, "quoted_tweet"); ok {
 			if data, ok := v.(*entities.TwitterPost); ok {
 				tw.QuotedTweet = data
@@ -310,50 +488,70 @@ func makeTweet(tweet TweetRaw, user entities.TwitterUser, card entities.TwitterC
 	return tw
 }
 
-func tweetToTweet(tweet TweetRaw, obj twitterResponse) *entities.TwitterPost {
+// tweetToTweet converts a globalObjects tweet. A non-nil error with a non-nil
+// post means the tweet was parsed but part of it (e.g. the card) was not.
+func tweetToTweet(tweet TweetRaw, obj twitterResponse) (*entities.TwitterPost, error) {
+	var cardErr error
 	user := parseUser(obj.GlobalObjects.Users[tweet.UserIDStr], 0)
 	// retweet data
-	tweetList := make(map[string]*entities.TwitterPost)
+	tweetList := make(map[string]interface{})
 	if tweet.RetweetedStatusIDStr != "" {
-		tweetList["retweeted_tweet"] = tweetToTweet(obj.GlobalObjects.Tweets[tweet.RetweetedStatusIDStr], obj)
+		retweeted, err := tweetToTweet(obj.GlobalObjects.Tweets[tweet.RetweetedStatusIDStr], obj)
+		if retweeted != nil {
+			tweetList["retweeted_tweet"] = retweeted
+		}
+		cardErr = err
 	}
 	if tweet.QuotedStatusIDStr != "" {
-		tweetList["quoted_tweet"] = tweetToTweet(obj.GlobalObjects.Tweets[tweet.QuotedStatusIDStr], obj)
+		quoted, err := tweetToTweet(obj.GlobalObjects.Tweets[tweet.QuotedStatusIDStr], obj)
+		if quoted != nil {
+			tweetList["quoted_tweet"] = quoted
+		}
+		if cardErr == nil {
+			cardErr = err
+		}
 	}
 
 	var card entities.TwitterCard
 	if tweet.Card != nil {
-		card = tweetCard(tweet.Card, getTweetId(tweet), APIStandart)
+		var err error
+		var poll *entities.TwitterPoll
+		card, poll, err = tweetCard(tweet.Card, getTweetId(tweet), APIStandart)
+		if err != nil {
+			cardErr = err
+		}
+		if poll != nil {
+			tweetList["poll"] = poll
+		}
 	}
-	return makeTweet(tweet, user, card, tweetList)
+	return makeTweet(tweet, user, card, tweetList), cardErr
 }
 
-func retrieveTweetData(entryID string, content map[string]interface{}, obj twitterResponse) *entities.TwitterPost {
+func retrieveTweetData(entryID string, content map[string]interface{}, obj twitterResponse) (*entities.TwitterPost, error) {
 	var tweet TweetRaw
 	if v, ok := content["tweet"]; ok {
 		val := v.(map[string]interface{})
 		if _, ok := val["promotedMetadata"]; ok {
-			return nil
+			return nil, nil
 		}
 		if _, ok := obj.GlobalObjects.Tweets[val["id"].(string)]; !ok {
 			log.Println("WARN: skipping tweet", val["id"].(string), "which is not in globalObjects")
-			return nil
+			return nil, nil
 		}
 		tweet = obj.GlobalObjects.Tweets[val["id"].(string)]
 	} else if v, ok := content["tombstone"]; ok {
 		val := v.(map[string]interface{})
-		if _, ok := val["tweet"]; ok { // E.g. deleted reply
-			return nil
+		if _, ok := val["tweet"]; !ok { // E.g. deleted reply
+			return nil, nil
 		}
 		id := val["tweet"].(map[string]interface{})["id"].(string)
 		if _, ok := obj.GlobalObjects.Tweets[id]; !ok {
-			log.Println("WARN: skipping tweet", val["id"].(string), "which is not in globalObjects")
+			log.Println("WARN: skipping tweet", id, "which is not in globalObjects")
+			return nil, nil
 		}
 		tweet = obj.GlobalObjects.Tweets[id]
 	} else {
-		//raise error
-		log.Println("ERROR: unable to handle entry", entryID)
-		return nil
+		return nil, newError(ErrSchemaChanged, "search", "unable to handle entry %s", entryID)
 	}
 
 	return tweetToTweet(tweet, obj)
@@ -362,14 +560,14 @@ func retrieveTweetData(entryID string, content map[string]interface{}, obj twitt
 func retrieveGraphqlTimeline(result *utils.DictType) (*entities.TwitterPost, error) {
 	if result.M("__typename").String() == "Tweet" {
 	} else if result.M("__typename").String() == "TweetWithVisibilityResults" {
-		panic("TODO")
+		result = result.M("tweet")
 	} else {
-		return nil, fmt.Errorf("unknown result type %s", result.M("__typename").String())
+		return nil, newError(ErrSchemaChanged, "timeline", "unknown result type %s", result.M("__typename").String())
 	}
 	userId, _ := strconv.Atoi(result.M("core").M("user_results").M("result").M("rest_id").String())
 	legacy, err := json.Marshal(result.M("core").M("user_results").M("result").M("legacy").Interface())
 	if err != nil {
-		return nil, fmt.Errorf("json.Marshal unknown result type %s", result.M("__typename").String())
+		return nil, wrapError(ErrSchemaChanged, "timeline", err)
 	}
 	var userRaw TweetUsers
 	_ = json.Unmarshal(legacy, &userRaw)
@@ -379,27 +577,33 @@ func retrieveGraphqlTimeline(result *utils.DictType) (*entities.TwitterPost, err
 	tweet := result.M("legacy")
 	tweetList := make(map[string]interface{})
 	if v, ok := tweet.Exists("retweeted_status_result"); ok {
-		retweeted, err := retrieveGraphqlTimeline(v.M("result"))
-		if err == nil {
+		retweeted, _ := retrieveGraphqlTimeline(v.M("result"))
+		if retweeted != nil {
 			tweetList["retweeted_tweet"] = retweeted
 		}
 	}
 
+	var cardErr error
 	if v, ok := result.Exists("quoted_status_result"); ok {
 		if v.M("result").M("__typename").String() == "TweetTombstone" {
-			panic("TweetTombstone")
+			// deleted or withheld quoted tweet, keep only the reference
+			id, _ := strconv.Atoi(tweet.M("quoted_status_id_str").String())
+			tf := &entities.TweetRef{Id: id}
+			tf.SetUrl(id)
+			tweetList["quoted_tweet"] = tf
 		} else {
-			quotedTweet, err := retrieveGraphqlTimeline(result)
-			if err == nil {
+			quotedTweet, err := retrieveGraphqlTimeline(v.M("result"))
+			if quotedTweet != nil {
 				tweetList["quoted_tweet"] = quotedTweet
 			}
+			cardErr = err
 		}
 	} else if v, ok := result.Exists("quotedRefResult"); ok {
 		tf := &entities.TweetRef{}
 		if v.M("result").M("__typename").String() == "TweetTombstone" {
 			tf.Id, _ = strconv.Atoi(tweet.M("quoted_status_id_str").String())
 		} else {
-			tf.Id, _ = strconv.Atoi(result.M("rest_id").String())
+			tf.Id, _ = strconv.Atoi(v.M("result").M("rest_id").String())
 		}
 		tf.SetUrl(tf.Id)
 		tweetList["quoted_tweet"] = tf
@@ -417,19 +621,122 @@ func retrieveGraphqlTimeline(result *utils.DictType) (*entities.TwitterPost, err
 		_ = json.Unmarshal(raw, &card)
 
 		tweetId, _ := strconv.Atoi(tweet.M("id_str").String())
-		tc = tweetCard(card, tweetId, APIGraphql)
+		var poll *entities.TwitterPoll
+		tc, poll, err = tweetCard(card, tweetId, APIGraphql)
+		if err != nil {
+			cardErr = err
+		}
+		if poll != nil {
+			tweetList["poll"] = poll
+		}
 	}
 
 	js, err := json.Marshal(tweet.Interface())
 	if err != nil {
-		return nil, fmt.Errorf("json.Marshal unknown tweet result")
+		return nil, wrapError(ErrSchemaChanged, "timeline", err)
 	}
 	var tweetRaw TweetRaw
 	if err := json.Unmarshal(js, &tweetRaw); err != nil {
-		return nil, fmt.Errorf("json.Unmarshal unknown tweet result")
+		return nil, wrapError(ErrSchemaChanged, "timeline", err)
+	}
+
+	return makeTweet(tweetRaw, user, tc, tweetList), cardErr
+}
+
+// mediaSizes are the size names of media images, smallest first.
+var mediaSizes = []string{"thumb", "small", "medium", "large"}
+
+func parseMedia(media TweetMedia) entities.TwitterMedia {
+	m := entities.TwitterMedia{
+		Type:              entities.MediaType(media.Type),
+		MediaKey:          media.MediaKey,
+		Url:               media.MediaURLHttps,
+		Width:             media.OriginalInfo.Width,
+		Height:            media.OriginalInfo.Height,
+		AltText:           media.ExtAltText,
+		Available:         media.ExtMediaAvailability.Status != "Unavailable",
+		UnavailableReason: media.ExtMediaAvailability.Reason,
+	}
+	m.Id, _ = strconv.Atoi(media.IDStr)
+
+	for _, name := range mediaSizes {
+		if size, ok := media.Sizes[name]; ok {
+			m.Sizes = append(m.Sizes, entities.TwitterMediaSize{
+				Name:   name,
+				Url:    mediaUrl(media.MediaURLHttps, name),
+				Width:  size.W,
+				Height: size.H,
+				Resize: size.Resize,
+			})
+		}
+	}
+	if m.Width > 0 && m.Height > 0 {
+		m.Sizes = append(m.Sizes, entities.TwitterMediaSize{
+			Name:   "orig",
+			Url:    mediaUrl(media.MediaURLHttps, "orig"),
+			Width:  m.Width,
+			Height: m.Height,
+			Resize: "fit",
+		})
+	}
+
+	switch m.Type {
+	case entities.MediaPhoto:
+		m.PreviewUrl = mediaUrl(media.MediaURLHttps, "small")
+		m.FullUrl = mediaUrl(media.MediaURLHttps, "large")
+		if m.FullUrl == "" {
+			m.PreviewUrl, m.FullUrl = media.MediaURLHttps, media.MediaURLHttps
+		}
+	case entities.MediaVideo, entities.MediaGif:
+		for _, variant := range media.VideoInfo.Variants {
+			m.Variants = append(m.Variants, entities.TwitterVideoVariant{
+				ContentType: variant.ContentType,
+				Url:         variant.URL,
+				BitRate:     variant.Bitrate,
+			})
+		}
+		if m.Type == entities.MediaVideo {
+			m.Duration = float64(media.VideoInfo.DurationMillis) / 1000
+			m.Views = mediaViews(media)
+		}
+	}
+	return m
+}
+
+// mediaUrl returns the name size of the media image u, empty when u is not a
+// jpg or png image.
+func mediaUrl(u, name string) string {
+	if i := strings.Index(u, "?"); i >= 0 {
+		query, err := url.ParseQuery(u[i+1:])
+		if err != nil || query.Get("format") == "" {
+			return ""
+		}
+		query.Set("name", name)
+		return u[:i] + "?" + query.Encode()
 	}
+	ext := regexExt.FindString(u)
+	format := strings.TrimPrefix(ext, ".")
+	if format != "jpg" && format != "png" {
+		return ""
+	}
+	return fmt.Sprintf("%s?format=%s&name=%s", strings.TrimSuffix(u, ext), format, name)
+}
 
-	return makeTweet(tweetRaw, user, tc, tweetList), nil //todo panic error
+// mediaViews returns the view count of a video, a string in adaptive.json
+// and a number in GraphQL.
+func mediaViews(media TweetMedia) int {
+	stats := media.MediaStats
+	if r, ok := media.Ext.MediaStats["r"].(map[string]interface{}); ok {
+		stats, _ = r["ok"].(map[string]interface{})
+	}
+	switch v := stats["viewCount"].(type) {
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	case float64:
+		return int(v)
+	}
+	return 0
 }
 
 func parseUser(user TweetUsers, userId int) entities.TwitterUser {
@@ -439,8 +746,31 @@ func parseUser(user TweetUsers, userId int) entities.TwitterUser {
 		Username:         user.ScreenName,
 		DisplayName:      user.Name,
 		RawDescription:   user.Description,
-		Description:      strings.Join([]string{user.Description, strings.Join(renderTextWithUrls(user.Description, user.Entities.URL.Urls), ", ")}, " | "),
-		DescriptionLinks: renderTextWithUrls(user.Description, user.Entities.URL.Urls),
+		Description:      strings.Join([]string{user.Description, strings.Join(renderTextWithUrls(user.Description, user.Entities.Description.Urls), ", ")}, " | "),
+		DescriptionLinks: renderTextWithUrls(user.Description, user.Entities.Description.Urls),
+		HasNftAvatar:     user.HasNftAvatar,
+	}
+	if entities.Id == 0 {
+		// GraphQL legacy users carry their ID in rest_id only
+		entities.Id = userId
+	}
+	for _, u := range user.Entities.Description.Urls {
+		entities.DescriptionUrls = append(entities.DescriptionUrls, u.ExpandedURL)
+	}
+	for _, u := range user.Entities.URL.Urls {
+		if u.Url == user.Url {
+			entities.ExpandedUrl = u.ExpandedURL
+		}
+	}
+	for _, idStr := range user.PinnedTweetIdsStr {
+		if id, err := strconv.Atoi(idStr); err == nil {
+			entities.PinnedTweetIds = append(entities.PinnedTweetIds, id)
+		}
+	}
+	for _, country := range user.WithheldInCountries {
+		if code, ok := country.(string); ok {
+			entities.WithheldInCountries = append(entities.WithheldInCountries, code)
+		}
 	}
 	entities.Created = utils.RubyDate(user.CreatedAt)
 	entities.FollowersCount = user.FollowersCount
@@ -470,8 +800,8 @@ func parseUser(user TweetUsers, userId int) entities.TwitterUser {
 	return entities
 }
 
-func parseTimeline(timeline twitterResponse, gotPinned *bool, dateRange DateRange) []*entities.TwitterPost {
-	tweets := make([]*entities.TwitterPost, 0)
+func parseTimeline(timeline twitterResponse, gotPinned *bool, since, until time.Time) []*TweetResult {
+	tweets := make([]*TweetResult, 0)
 
 	for _, instruction := range timeline.Timeline.Instructions {
 		entries := checkEntries(instruction)
@@ -480,33 +810,43 @@ func parseTimeline(timeline twitterResponse, gotPinned *bool, dateRange DateRang
 		}
 
 		for _, obj := range entries {
-			entry := obj.(map[string]interface{})
-			if !(strings.HasPrefix(entry["entryId"].(string), "sq-I-t-") || strings.HasPrefix(entry["entryId"].(string), "tweet-")) {
+			entry, ok := obj.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			entryID, _ := entry["entryId"].(string)
+			if !(strings.HasPrefix(entryID, "sq-I-t-") || strings.HasPrefix(entryID, "tweet-")) {
+				continue
+			}
+			post, err := parseTimelineEntry(entryID, entry, timeline)
+			if post == nil && err == nil {
 				continue
 			}
-			tweets = append(tweets, retrieveTweetData(entry["entryId"].(string), entry["content"].(map[string]interface{})["item"].(map[string]interface{})["content"].(map[string]interface{}), timeline))
+			tweets = append(tweets, &TweetResult{TwitterPost: post, Error: err})
 		}
 	}
 	return tweets
 }
 
-func parseTimelineV2(timeline twitterResponse, gotPinned *bool, dateRange DateRange) []*entities.TwitterPost {
-	tweets := make([]*entities.TwitterPost, 0)
+func parseTimelineEntry(entryID string, entry map[string]interface{}, timeline twitterResponse) (post *entities.TwitterPost, err error) {
+	defer recoverSchema("search", entryID, &err)
+	content := entry["content"].(map[string]interface{})["item"].(map[string]interface{})["content"].(map[string]interface{})
+	return retrieveTweetData(entryID, content, timeline)
+}
+
+func parseTimelineV2(timeline twitterResponse, gotPinned *bool, since, until time.Time) []*TweetResult {
+	tweets := make([]*TweetResult, 0)
+	if timeline.Data.User == nil {
+		return tweets
+	}
 	if !*gotPinned {
 		for _, instruction := range timeline.Data.User.Result.Timeline.Timeline.Instructions {
-			if len(instruction.Entries) == 0 {
-				continue
-			}
-
 			if instruction.Type == "TimelinePinEntry" {
-				setPinned := true
-				gotPinned = &setPinned
-				panic("TimelinePinEntry")
-				// parse timeline func
+				*gotPinned = true
 			}
 		}
 	}
-	// func
+
 	for _, instruction := range timeline.Data.User.Result.Timeline.Timeline.Instructions {
 		entries := checkEntries(instruction)
 		if entries == nil {
@@ -519,24 +859,70 @@ func parseTimelineV2(timeline twitterResponse, gotPinned *bool, dateRange DateRa
 
 		for _, obj := range entries {
 			entry := utils.Dict(obj)
+			entryID, _ := entry.M("entryId").Interface().(string)
+			if !strings.HasPrefix(entryID, "tweet-") {
+				continue
+			}
 
-			if strings.HasPrefix(entry.M("entryId").String(), "tweet-") {
-				entryType := entry.M("content").M("entryType").String()
-				itemType := entry.M("content").M("itemContent").M("itemType").String()
-				if entryType == "TimelineTimelineItem" && itemType == "TimelineTweet" {
-					result, err := retrieveGraphqlTimeline(entry.M("content").M("itemContent").M("tweet_results").M("result"))
-					if err != nil {
-						continue
-					}
+			result, err := parseGraphqlEntry(entryID, entry)
+			if result == nil && err == nil {
+				continue
+			}
+			if result != nil && (result.Date == nil || !inTimeSpan(since, until, *result.Date)) {
+				continue
+			}
+			tweets = append(tweets, &TweetResult{TwitterPost: result, Error: err})
+		}
+	}
+	return tweets
+}
 
-					start, _ := time.Parse(datetimeLayout, dateRange.Since)
-					end, _ := time.Parse(datetimeLayout, dateRange.Until)
-					if inTimeSpan(start, end, *result.Date) {
-						tweets = append(tweets, result)
-					}
+func parseGraphqlEntry(entryID string, entry *utils.DictType) (post *entities.TwitterPost, err error) {
+	defer recoverSchema("timeline", entryID, &err)
+	entryType := entry.M("content").M("entryType").String()
+	itemType := entry.M("content").M("itemContent").M("itemType").String()
+	if entryType != "TimelineTimelineItem" || itemType != "TimelineTweet" {
+		log.Println("WARN: got unrecognised timeline tweet item(s)")
+		return nil, nil
+	}
+	result := entry.M("content").M("itemContent").M("tweet_results").M("result")
+	if typename, _ := result.M("__typename").Interface().(string); typename == "TweetTombstone" {
+		// deleted or withheld tweet
+		return nil, nil
+	}
+	return retrieveGraphqlTimeline(result)
+}
 
-				} else {
-					log.Println("WARN: got unrecognised timeline tweet item(s)")
+// parseThread parses the TweetDetail instructions: the focal tweet, its
+// ancestors and the conversation modules holding the replies.
+func parseThread(instructions []TweetInstructions) []*TweetResult {
+	tweets := make([]*TweetResult, 0)
+	add := func(post *entities.TwitterPost, err error) {
+		if post == nil && err == nil {
+			return
+		}
+		tweets = append(tweets, &TweetResult{TwitterPost: post, Error: err})
+	}
+
+	for _, instruction := range instructions {
+		if instruction.Type != "TimelineAddEntries" {
+			continue
+		}
+
+		for _, obj := range instruction.Entries {
+			entry := utils.Dict(obj)
+			entryID, _ := entry.M("entryId").Interface().(string)
+			if strings.HasPrefix(entryID, "tweet-") {
+				add(parseGraphqlEntry(entryID, entry))
+			} else if strings.HasPrefix(entryID, "conversationthread-") {
+				items, _ := entry.M("content").M("items").Interface().([]interface{})
+				for _, item := range items {
+					item := utils.Dict(item)
+					itemID, _ := item.M("entryId").Interface().(string)
+					if !strings.Contains(itemID, "-tweet-") {
+						continue
+					}
+					add(parseGraphqlItem(itemID, item.M("item").M("itemContent")))
 				}
 			}
 		}
@@ -544,6 +930,20 @@ func parseTimelineV2(timeline twitterResponse, gotPinned *bool, dateRange DateRa
 	return tweets
 }
 
+func parseGraphqlItem(entryID string, itemContent *utils.DictType) (post *entities.TwitterPost, err error) {
+	defer recoverSchema("thread", entryID, &err)
+	if itemContent.M("itemType").String() != "TimelineTweet" {
+		return nil, nil
+	}
+	result := itemContent.M("tweet_results").M("result")
+	if typename, _ := result.M("__typename").Interface().(string); typename == "TweetTombstone" {
+		// deleted or withheld reply
+		return nil, nil
+	}
+	return retrieveGraphqlTimeline(result)
+}
+
+// inTimeSpan reports whether check is in [start, end), a zero bound is open.
 func inTimeSpan(start, end, check time.Time) bool {
-	return check.After(start) && check.Before(end)
+	return (start.IsZero() || !check.Before(start)) && (end.IsZero() || check.Before(end))
 }
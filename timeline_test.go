@@ -0,0 +1,258 @@
+package sns
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hinha/go-social-network/entities"
+)
+
+func loadTimeline(t *testing.T, name string) twitterResponse {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj twitterResponse
+	if err := json.Unmarshal(data, &obj); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func postIds(results []*TweetResult) []int {
+	ids := make([]int, 0, len(results))
+	for _, r := range results {
+		if r.TwitterPost != nil {
+			ids = append(ids, r.Id)
+		}
+	}
+	return ids
+}
+
+func TestParseTimeline(t *testing.T) {
+	var pinned bool
+	results := parseTimeline(loadTimeline(t, "adaptive.json"), &pinned, time.Time{}, time.Time{})
+	for _, r := range results {
+		if r.Error != nil {
+			t.Fatalf("tweet %v: %v", r.TwitterPost, r.Error)
+		}
+	}
+	// the promoted tweet and the tombstone without a tweet are skipped
+	if got, want := postIds(results), []int{1346800000000000003, 1346800000000000002, 1346800000000000001}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tweets %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		post  *entities.TwitterPost
+		check func(t *testing.T, post *entities.TwitterPost)
+	}{
+		{"photo", results[2].TwitterPost, func(t *testing.T, post *entities.TwitterPost) {
+			if post.User.Username != "gopher_scrubbed" || !post.User.Verified {
+				t.Errorf("user %+v", post.User)
+			}
+			if !reflect.DeepEqual(post.Hashtags, []string{"golang"}) || post.LikeCount != 40 || post.SourceLabel != "Twitter Web App" {
+				t.Errorf("hashtags %v, likes %d, source %q", post.Hashtags, post.LikeCount, post.SourceLabel)
+			}
+			if len(post.Links) != 1 || post.Links[0].Url != "https://go.dev/doc/go1.16" {
+				t.Errorf("links %+v", post.Links)
+			}
+			if len(post.Media) != 1 {
+				t.Fatalf("media %+v", post.Media)
+			}
+			media := post.Media[0]
+			if media.Type != entities.MediaPhoto || media.AltText != "Gopher" || !media.Available ||
+				media.FullUrl != "https://pbs.twimg.com/media/ErScrubbedPhoto?format=jpg&name=large" {
+				t.Errorf("media %+v", media)
+			}
+			if n := len(media.Sizes); n != 4 || media.Sizes[n-1].Name != "orig" || media.Sizes[n-1].Width != 1200 {
+				t.Errorf("sizes %+v", media.Sizes)
+			}
+		}},
+		{"retweet", results[1].TwitterPost, func(t *testing.T, post *entities.TwitterPost) {
+			if post.RetweetedTweet == nil || post.RetweetedTweet.Id != 1346800000000000001 {
+				t.Fatalf("retweeted %+v", post.RetweetedTweet)
+			}
+			if post.RetweetedTweet.User.Username != "gopher_scrubbed" || len(post.RetweetedTweet.Media) != 1 {
+				t.Errorf("retweeted %+v", post.RetweetedTweet)
+			}
+		}},
+		{"reply with summary card", results[0].TwitterPost, func(t *testing.T, post *entities.TwitterPost) {
+			if post.InReplyToTweetId != 1346800000000000001 || post.InReplyToUser.Username != "gopher_scrubbed" {
+				t.Errorf("reply to %d %+v", post.InReplyToTweetId, post.InReplyToUser)
+			}
+			summary := post.Card.SummaryCard
+			if summary == nil {
+				t.Fatalf("card %+v", post.Card)
+			}
+			if summary.Title != "What's new in Go 1.16" || summary.Domain != "example.com" || !summary.LargeImage ||
+				summary.ThumbnailUrl == "" || len(summary.ThumbnailColors) != 1 {
+				t.Errorf("summary %+v", summary)
+			}
+			if summary.SiteUser == nil || summary.SiteUser.Username != "example_scrubbed" {
+				t.Errorf("site user %+v", summary.SiteUser)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, tt.post)
+		})
+	}
+}
+
+func TestParseTimelineV2(t *testing.T) {
+	var pinned bool
+	since, until, err := DateRange{Since: "2021-01-01", Until: "2021-01-31"}.window()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := parseTimelineV2(loadTimeline(t, "UserTweetsAndReplies.json"), &pinned, since, until)
+	for _, r := range results {
+		if r.Error != nil {
+			t.Fatalf("tweet %v: %v", r.TwitterPost, r.Error)
+		}
+	}
+	if !pinned {
+		t.Error("pinned entry not seen")
+	}
+	// the tombstone and the tweet before Since are skipped
+	if got, want := postIds(results), []int{1346900000000000002, 1346900000000000001}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tweets %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		post  *entities.TwitterPost
+		check func(t *testing.T, post *entities.TwitterPost)
+	}{
+		{"poll", results[0].TwitterPost, func(t *testing.T, post *entities.TwitterPost) {
+			if post.User.Id != 1000000001 || post.User.Username != "gopher_scrubbed" {
+				t.Errorf("user %+v", post.User)
+			}
+			poll := post.Poll
+			if poll == nil {
+				t.Fatal("no poll")
+			}
+			want := []entities.TwitterPollOption{{Label: "generics", Count: 1204}, {Label: "embed", Count: 310}, {Label: "fuzzing", Count: 86}}
+			if !reflect.DeepEqual(poll.Options, want) || poll.TotalVotes != 1600 || !poll.Final || poll.DurationMinutes != 1440 {
+				t.Errorf("poll %+v", poll)
+			}
+			if poll.EndDate == nil || poll.EndDate.Format(datetimeLayout) != "2021-01-08 10:00:00" {
+				t.Errorf("end date %v", poll.EndDate)
+			}
+		}},
+		{"video quoting a tombstone", results[1].TwitterPost, func(t *testing.T, post *entities.TwitterPost) {
+			if post.QuotedTweetRef == nil || post.QuotedTweetRef.Id != 1346900000000000050 || post.QuotedTweet != nil {
+				t.Errorf("quoted %+v %+v", post.QuotedTweetRef, post.QuotedTweet)
+			}
+			if len(post.Media) != 1 {
+				t.Fatalf("media %+v", post.Media)
+			}
+			media := post.Media[0]
+			if media.Type != entities.MediaVideo || len(media.Variants) != 3 || media.Duration != 12.5 || media.Views != 3141 {
+				t.Errorf("media %+v", media)
+			}
+			if u, ext := bestVariant(media); ext != ".mp4" || u != "https://video.twimg.com/ext_tw_video/1346899999000000001/pu/vid/1280x720/scrubbed.mp4" {
+				t.Errorf("best variant %s %s", u, ext)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, tt.post)
+		})
+	}
+}
+
+func TestTweetCard(t *testing.T) {
+	tests := []struct {
+		file  string
+		api   VersionAPI
+		check func(t *testing.T, card entities.TwitterCard, poll *entities.TwitterPoll)
+		err   error
+	}{
+		{"player.json", APIStandart, func(t *testing.T, card entities.TwitterCard, poll *entities.TwitterPoll) {
+			player := card.PlayerCard
+			if player == nil {
+				t.Fatalf("card %+v", card)
+			}
+			if player.Title != "Gophers at work" || player.PlayerUrl != "https://www.youtube.com/embed/scrubbed" ||
+				player.Width != 1280 || player.Height != 720 || len(player.ThumbnailColors) != 2 {
+				t.Errorf("player %+v", player)
+			}
+			if player.SiteUser == nil || player.SiteUser.Username != "YouTube" {
+				t.Errorf("site user %+v", player.SiteUser)
+			}
+		}, nil},
+		{"poll2choice_image.json", APIStandart, func(t *testing.T, card entities.TwitterCard, poll *entities.TwitterPoll) {
+			if card != (entities.TwitterCard{}) {
+				t.Errorf("card %+v, want the poll only", card)
+			}
+			if poll == nil || len(poll.Options) != 2 || poll.TotalVotes != 49 || poll.Final ||
+				poll.ImageUrl == "" || poll.LastUpdated == nil {
+				t.Errorf("poll %+v", poll)
+			}
+		}, nil},
+		{"app.graphql.json", APIGraphql, func(t *testing.T, card entities.TwitterCard, poll *entities.TwitterPoll) {
+			app := card.AppCard
+			if app == nil {
+				t.Fatalf("card %+v", card)
+			}
+			if app.AppName != "Gopher Run" || app.IPhoneId != "1234567890" || app.GooglePlayId != "dev.example.gopherrun" ||
+				app.Rating != 4.5 || app.Ratings != 12345 || !app.Free || len(app.ThumbnailColors) != 1 {
+				t.Errorf("app %+v", app)
+			}
+			if app.SiteUser == nil || app.SiteUser.Id != 1000000004 || app.SiteUser.Username != "gopherrun_scrubbed" {
+				t.Errorf("site user %+v", app.SiteUser)
+			}
+		}, nil},
+		{"broadcast.graphql.json", APIGraphql, func(t *testing.T, card entities.TwitterCard, poll *entities.TwitterPoll) {
+			broadcast := card.Broadcast
+			if broadcast == nil {
+				t.Fatalf("card %+v", card)
+			}
+			if broadcast.Id != "1aBcDeFgHiJkL" || broadcast.State != "ENDED" || broadcast.Title != "Live from GopherCon" {
+				t.Errorf("broadcast %+v", broadcast)
+			}
+			if broadcast.Broadcaster == nil || broadcast.Broadcaster.Username != "gophercon_scrubbed" {
+				t.Errorf("broadcaster %+v", broadcast.Broadcaster)
+			}
+		}, nil},
+		{"unified_card.json", APIStandart, func(t *testing.T, card entities.TwitterCard, poll *entities.TwitterPoll) {
+			generic := card.Generic
+			if generic == nil || generic.Name != "unified_card" {
+				t.Fatalf("card %+v", card)
+			}
+			if generic.BindingValues["card_url"] != "https://t.co/HHHHHHHHHH" || generic.BindingValues["is_promoted"] != false {
+				t.Errorf("binding values %+v", generic.BindingValues)
+			}
+		}, nil},
+		{"noname.graphql.json", APIGraphql, nil, ErrUnsupportedCard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "cards", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var raw *TweetRawCard
+			if err := json.Unmarshal(data, &raw); err != nil {
+				t.Fatal(err)
+			}
+			card, poll, err := tweetCard(raw, 1, tt.api)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if tt.check != nil {
+				tt.check(t, card, poll)
+			}
+		})
+	}
+}
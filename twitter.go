@@ -11,20 +11,29 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hinha/go-social-network/logger"
+	"github.com/hinha/go-social-network/store"
 	"github.com/hinha/go-social-network/utils"
 )
 
+var regexGuestToken = regexp.MustCompile(`document\.cookie = decodeURIComponent\("gt=(\d+); Max-Age=(\d+); Domain=\.twitter\.com; Path=/; Secure"\);`)
+
+// Default endpoints, Config.Endpoints overrides them.
 const (
-	ApiAuthorizationHeader   = "Bearer AAAAAAAAAAAAAAAAAAAAANRILgAAAAAAnNwIzUejRCOuH5E6I8xnZz4puTs%3D1Zv7ttfk8LF81IUq16cHjhLTvJu4FA33AGWWjCpTnA"
-	TwitterAPIToken          = "https://api.twitter.com/1.1/guest/activate.json"
-	TwitterAPISearch         = "https://api.twitter.com/2/search/adaptive.json"
+	ApiAuthorizationHeader = "Bearer AAAAAAAAAAAAAAAAAAAAANRILgAAAAAAnNwIzUejRCOuH5E6I8xnZz4puTs%3D1Zv7ttfk8LF81IUq16cHjhLTvJu4FA33AGWWjCpTnA"
+	TwitterAPIToken        = "https://api.twitter.com/1.1/guest/activate.json"
+	TwitterAPISearch       = "https://api.twitter.com/2/search/adaptive.json"
+
+	// Deprecated: use Endpoints, the query IDs are rotated.
 	TwitterAPIUserScreenName = "https://twitter.com/i/api/graphql/7mjxD3-C6BxitPMVQ6w0-Q/UserByScreenName"
-	TwitterAPIUserTweets     = "https://twitter.com/i/api/graphql/BSKxQ9_IaCoVyIvQHQROIQ/UserTweetsAndReplies"
+	// Deprecated: use Endpoints, the query IDs are rotated.
+	TwitterAPIUserTweets = "https://twitter.com/i/api/graphql/BSKxQ9_IaCoVyIvQHQROIQ/UserTweetsAndReplies"
 )
 
 // var (
@@ -33,14 +42,30 @@ const (
 // 	reUsername   = regexp.MustCompile(`\B(\@\S{1,15}\b)`)
 // )
 
+// SearchMode selects the flow of TwitterScraper.Search.
 type SearchMode int
 
 const (
+	// SearchKeyword live search of tweets, see TweetSearch.
 	SearchKeyword SearchMode = iota
+	// SearchUser search of accounts, see UserSearch.
 	SearchUser
+	// SearchHashtag search of a hashtag, see HashtagSearch.
 	SearchHashtag
 )
 
+func (m SearchMode) String() string {
+	switch m {
+	case SearchKeyword:
+		return "keyword"
+	case SearchUser:
+		return "user"
+	case SearchHashtag:
+		return "hashtag"
+	}
+	return "unknown"
+}
+
 type VersionAPI string
 
 const (
@@ -48,30 +73,50 @@ const (
 	APIGraphql  VersionAPI = "GRAPHQL.v2"
 )
 
-var (
-	wg sync.WaitGroup
-)
-
+// TwitterScraper is safe for concurrent use, see Runner to run many
+// searches at once.
 type TwitterScraper struct {
-	scraper    *Scraper
-	config     *Config
+	// flushed is the token pool version last saved to the store, first for
+	// the alignment of atomic access
+	flushed uint64
+
+	scraper *Scraper
+	config  *Config
+	// mu guards apiHeaders and userAgent
+	mu         sync.Mutex
 	apiHeaders http.Header
 	userAgent  string
 	//guestToken string
-	query        string
 	tokenManager *utils.GuestTokenManager
+	tokenPool    *utils.TokenPool
+	store        store.Store
+	checkpoints  store.Store
+	endpoints    Endpoints
 }
 
-func NewTwitterScraper(conf *Config) *TwitterScraper {
-	s := &TwitterScraper{}
+// NewTwitterScraper creates a scraper for conf. Options tune the http
+// transport and the shared pools, see WithHTTPClient, WithTransport,
+// WithRequestTimeout, WithTLSConfig, WithProxyPool and WithTokenPool. It fails
+// when Config.Date is invalid or the options cannot be combined.
+func NewTwitterScraper(conf *Config, opts ...Option) (*TwitterScraper, error) {
+	s := &TwitterScraper{endpoints: DefaultEndpoints()}
+	o := newOptions(opts)
 
 	if conf != nil {
 		if conf.Logger == nil {
 			conf.Logger = logger.Default
 		}
 		conf.Logger.SetField("media", "twitter")
-		s.scraper = newScraper(conf)
+		scraper, err := newScraper(conf, o)
+		if err != nil {
+			return nil, err
+		}
+		s.scraper = scraper
 		s.config = conf
+		s.endpoints = conf.Endpoints.orDefault()
+		if s.scraper.retry.RotateIdentity {
+			s.scraper.beforeRetry = s.rotateIdentity
+		}
 	}
 
 	rand.Seed(time.Now().UnixNano())
@@ -81,122 +126,212 @@ func NewTwitterScraper(conf *Config) *TwitterScraper {
 	s.apiHeaders = header
 	s.randomUserAgent()
 	s.tokenManager = utils.TokenManager()
+	s.tokenPool = o.tokenPool
+	if s.tokenPool == nil {
+		s.tokenPool = utils.NewTokenPool(1)
+	}
+	s.store = o.store
+	s.checkpoints = o.checkpoints
+	if s.checkpoints == nil {
+		s.checkpoints = o.store
+	}
+	if s.store != nil && s.scraper != nil {
+		if err := s.loadStore(context.Background()); err != nil {
+			conf.Logger.Error(time.Now(), "load store: ", err)
+		}
+		s.flushed = s.tokenPool.Version()
+	}
 
-	return s
+	return s, nil
 }
 
 func (c *TwitterScraper) randomUserAgent() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.userAgent = fmt.Sprintf("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/79.0.3945.%d Safari/537.%d", rand.Intn(9999), rand.Intn(99))
 	c.apiHeaders.Set("User-Agent", c.userAgent)
 }
 
-func (c *TwitterScraper) ensureGuestToken(baseUrl string) error {
-	beginAt := time.Now()
-
-	header := http.Header{}
-	if c.tokenManager.Token == "" {
-		header.Add("User-Agent", c.apiHeaders.Get("User-Agent"))
-		r, err := c.scraper.RequestGET(baseUrl, "", header, c.CheckTokenResponse)
-		if err != nil {
-			c.config.Logger.Error(beginAt, err)
-			return err
-		}
-		defer r.Body.Close()
-		c.config.Logger.SetField("subject", "token")
-
-		resp, err := io.ReadAll(r.Body)
-		if err != nil {
-			return err
-		}
+// headers returns a copy of the api headers for one request.
+func (c *TwitterScraper) headers() http.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.apiHeaders.Clone()
+}
 
-		full, _ := regexp.Compile(`document\.cookie = decodeURIComponent\("gt=(\d+); Max-Age=10800; Domain=\.twitter\.com; Path=/; Secure"\);`)
-		match := full.FindStringSubmatch(string(resp))
-		if len(match) > 1 {
-			c.config.Logger.Debug(beginAt, "Found guest token in HTML")
-			c.tokenManager.SetToken(match[1])
-		}
-		for _, cookie := range r.Cookies() {
-			c.config.Logger.Debug(beginAt, "Found guest token in cookies")
-			if cookie.Name == "gt" {
-				c.tokenManager.SetToken(cookie.Value)
-				break
-			}
-		}
-		if c.tokenManager.Token == "" {
-			c.config.Logger.Debug(beginAt, "No guest token in response")
-			c.config.Logger.Info(beginAt, "Retrieving guest token via API")
-			r, err := c.scraper.RequestPOST(TwitterAPIToken, "", bytes.NewReader([]byte("")), c.apiHeaders, c.CheckTokenResponse)
-			if err != nil {
-				return err
-			}
-			defer r.Body.Close()
+func (c *TwitterScraper) setHeader(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apiHeaders.Set(key, value)
+}
 
-			var result map[string]interface{}
-			if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
-				return err
-			}
+// ensureGuestToken takes a guest token from the pool, fetching one through
+// baseUrl when the pool is not full yet, and attaches it to the api headers
+// and the cookie jar.
+func (c *TwitterScraper) ensureGuestToken(ctx context.Context, baseUrl string) error {
+	beginAt := time.Now()
 
-			if val, ok := result["guest_token"]; ok {
-				c.tokenManager.SetToken(val.(string))
-			}
-		}
-		c.config.Logger.Debug(beginAt, "Using guest token ", c.tokenManager.Token)
+	token, err := c.tokenPool.Get(ctx, func(ctx context.Context) (string, time.Duration, error) {
+		return c.fetchGuestToken(ctx, baseUrl)
+	})
+	if err != nil {
+		return err
+	}
+	changed := token.Value != c.tokenManager.GetToken()
+	if changed {
+		c.config.Logger.Debug(beginAt, "Using guest token ", token.Value)
 	}
+	c.tokenManager.SetTokenExpiry(token.Value, token.ExpiresAt)
+
 	cookie := make([]*http.Cookie, 0)
 	cookie = append(cookie, &http.Cookie{
 		Name:    "gt",
 		Domain:  ".twitter.com",
 		Path:    "/",
 		Secure:  true,
-		Value:   c.tokenManager.Token,
-		Expires: c.tokenManager.GetTime().Add(time.Duration(10800) * time.Second),
+		Value:   token.Value,
+		Expires: token.ExpiresAt,
 	})
 	URL, _ := url.Parse(baseUrl)
 	c.scraper.GetClient().Jar.SetCookies(URL, cookie)
-	c.apiHeaders.Add("x-guest-token", c.tokenManager.Token)
+	c.setHeader("x-guest-token", token.Value)
+
+	// round robin over the pool is not worth a write, new and retired
+	// tokens are
+	if version := c.tokenPool.Version(); atomic.SwapUint64(&c.flushed, version) != version {
+		if err := c.Flush(ctx); err != nil {
+			c.config.Logger.Error(beginAt, "flush store: ", err)
+		}
+	}
 	return nil
 }
 
-func (c *TwitterScraper) get_api_data(endpoint string, params url.Values, apiType VersionAPI) (twitterResponse, error) {
+// fetchGuestToken retrieves a new guest token from the HTML of baseUrl, or
+// from the activate endpoint when the page does not carry one.
+func (c *TwitterScraper) fetchGuestToken(ctx context.Context, baseUrl string) (string, time.Duration, error) {
+	beginAt := time.Now()
+
+	var token string
+	var maxAge time.Duration
+
+	header := http.Header{}
+	header.Add("User-Agent", c.headers().Get("User-Agent"))
+	r, err := c.scraper.RequestGETContext(ctx, baseUrl, "", header, nil)
+	if err != nil {
+		c.config.Logger.Error(beginAt, err)
+		return "", 0, err
+	}
+	defer r.Body.Close()
+	c.config.Logger.SetField("subject", "token")
+
+	resp, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	match := regexGuestToken.FindStringSubmatch(string(resp))
+	if len(match) > 2 {
+		c.config.Logger.Debug(beginAt, "Found guest token in HTML")
+		token = match[1]
+		seconds, _ := strconv.Atoi(match[2])
+		maxAge = time.Duration(seconds) * time.Second
+	}
+	for _, cookie := range r.Cookies() {
+		if cookie.Name == "gt" {
+			c.config.Logger.Debug(beginAt, "Found guest token in cookies")
+			token = cookie.Value
+			if cookie.MaxAge > 0 {
+				maxAge = time.Duration(cookie.MaxAge) * time.Second
+			}
+			break
+		}
+	}
+	if token == "" {
+		c.config.Logger.Debug(beginAt, "No guest token in response")
+		c.config.Logger.Info(beginAt, "Retrieving guest token via API")
+		header := c.headers()
+		header.Del("x-guest-token")
+		r, err := c.scraper.RequestPOSTContext(ctx, c.endpoints.Token, "", bytes.NewReader([]byte("")), header, nil)
+		if err != nil {
+			return "", 0, err
+		}
+		defer r.Body.Close()
+
+		if err := statusError("token", r); err != nil {
+			return "", 0, err
+		}
+
+		var result map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+			return "", 0, wrapError(ErrSchemaChanged, "token", err)
+		}
+
+		if val, ok := result["guest_token"].(string); ok {
+			token = val
+		}
+	}
+	if token == "" {
+		return "", 0, newError(ErrBlocked, "token", "no guest token in response")
+	}
+	return token, maxAge, nil
+}
+
+// retireGuestToken drops the current guest token from the pool after it was
+// rate limited or rejected, the next request fetches or picks another one.
+func (c *TwitterScraper) retireGuestToken() {
+	if token := c.tokenManager.GetToken(); token != "" {
+		c.tokenPool.Retire(token)
+	}
+	c.tokenManager.Reset()
+}
+
+func (c *TwitterScraper) get_api_data(ctx context.Context, endpoint string, params url.Values, apiType VersionAPI) (twitterResponse, error) {
 	var paramsEncode string
 	if apiType == APIStandart {
 		param := url.Values{}
-		param.Add("q", c.query)
+		param.Add("q", params.Get("q"))
 		param.Add("f", "live")
 		param.Add("lang", "en")
 		param.Add("src", "spelling_expansion_revert_click")
-		apiBase := "https://twitter.com/search?"
-		if err := c.ensureGuestToken(apiBase + param.Encode()); err != nil {
+		apiBase := c.endpoints.Web + "/search?"
+		if err := c.ensureGuestToken(ctx, apiBase+param.Encode()); err != nil {
 			return twitterResponse{}, err
 		}
 		paramsEncode = params.Encode()
 	} else if apiType == APIGraphql {
-		mapParams := make(map[string]string)
+		vars := make(map[string]interface{})
 		for k, v := range params {
-			mapParams[k] = v[0]
+			vars[k] = v[0]
 		}
-		strMap, _ := json.Marshal(mapParams)
-		paramsEncode += "variables=" + url.PathEscape(string(strMap))
+		paramsEncode += c.endpoints.graphqlParams(endpoint, vars)
 	}
 
-	resp, err := c.scraper.RequestGET(endpoint, paramsEncode, c.apiHeaders, c.CheckTokenResponse)
+	resp, err := c.scraper.RequestGETContext(ctx, endpoint, paramsEncode, c.headers(), c.CheckTokenResponse)
 	if err != nil {
 		return twitterResponse{}, err
 	}
 	defer resp.Body.Close()
+	if err := statusError("api", resp); err != nil {
+		if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrBlocked) {
+			c.retireGuestToken()
+		}
+		return twitterResponse{}, err
+	}
 
 	var tweetResult twitterResponse
 	err = json.NewDecoder(resp.Body).Decode(&tweetResult)
 	if _, ok := err.(*json.SyntaxError); ok {
-		return twitterResponse{}, errors.New("received invalid JSON from Twitter")
+		return twitterResponse{}, newError(ErrSchemaChanged, "api", "received invalid JSON from Twitter")
 	} else if err != nil {
-		return twitterResponse{}, fmt.Errorf("json.Decode: %v", err)
+		return twitterResponse{}, wrapError(ErrSchemaChanged, "api", err)
 	}
 
 	return tweetResult, nil
 }
 
-func (c *TwitterScraper) iteratorApiData(ctx context.Context, endpoint string, params url.Values, paginationParams url.Values, cursor string, maxTweet int, apiType VersionAPI, channel chan *TweetResult, fn parseTweets) {
+// iteratorApiData pages through endpoint from cursor, emitting up to
+// maxTweet results. A non-nil cp is updated and saved after every page.
+func (c *TwitterScraper) iteratorApiData(ctx context.Context, endpoint string, params url.Values, paginationParams url.Values, cursor string, maxTweet int, apiType VersionAPI, channel chan *TweetResult, fn parseTweets, cp *Checkpoint) {
 	beginAt := time.Now()
 	defer close(channel)
 
@@ -205,174 +340,216 @@ func (c *TwitterScraper) iteratorApiData(ctx context.Context, endpoint string, p
 		reqParams = params
 	} else {
 		reqParams = paginationParams
-		reqParams.Add("cursor", cursor)
+		reqParams.Set("cursor", cursor)
 	}
 
-	var bottomCursorAndStop interface{}
+	since, until := c.dateWindow()
 	var stopOnEmptyResponse bool
 	var emptyResponseOnCursor int
-	var tweetNum int
+	var tweetNum, lastTweetID, skipTo int
+	if cp != nil {
+		tweetNum, lastTweetID = cp.Count, cp.LastTweetID
+		// a page cut short is fetched again, skip what was already emitted
+		skipTo = cp.LastTweetID
+	}
 
 	for {
 		c.config.Logger.Info(beginAt, "Retrieving scroll page ", cursor)
-		obj, err := c.get_api_data(endpoint, reqParams, apiType)
+		obj, err := c.get_api_data(ctx, endpoint, reqParams, apiType)
 		if err != nil {
-			channel <- &TweetResult{Error: err}
+			sendResult(ctx, channel, &TweetResult{Error: err})
 			return
 		}
 
 		var goPinned bool
-
-		wg.Add(1)
-		go func(obj twitterResponse) {
-			defer wg.Done()
-			select {
-			case <-ctx.Done():
-				channel <- &TweetResult{Error: ctx.Err()}
-				return
-			default:
+		tweets := fn(obj, &goPinned, since, until)
+		if skipTo != 0 {
+			for i, tweet := range tweets {
+				if tweet.TwitterPost != nil && tweet.Id == skipTo {
+					tweets = tweets[i+1:]
+					break
+				}
 			}
-
-			tweets := fn(obj, &goPinned, c.config.Date)
-			if len(tweets) == 0 {
-				return
+			skipTo = 0
+		}
+		// error results are passed on without counting toward maxTweet
+		for _, tweet := range tweets {
+			if tweetNum >= maxTweet || !sendResult(ctx, channel, tweet) {
+				break
 			}
-
-			for _, tweet := range tweets {
-				select {
-				case <-ctx.Done():
-					if tweet != nil {
-						channel <- &TweetResult{Error: ctx.Err()}
-						return
-					}
-				default:
-				}
-
-				if tweetNum < maxTweet {
-					channel <- &TweetResult{TwitterPost: tweet}
-				} else {
-					return
-				}
+			if tweet.TwitterPost != nil {
 				tweetNum++
+				lastTweetID = tweet.Id
 			}
-		}(obj)
-		wg.Wait()
+		}
 
-		if tweetNum == maxTweet {
+		if tweetNum >= maxTweet || ctx.Err() != nil {
+			if cp != nil {
+				// the page may have been cut short, resume re-reads it
+				cp.Cursor, cp.Count, cp.LastTweetID = cursor, tweetNum, lastTweetID
+				cp.Done = tweetNum >= maxTweet
+				c.saveCheckpoint(cp)
+			}
 			break
 		}
 
-		var newCursor string
-		var promptCursor string
-		var newBottomCursorAndStop interface{}
-		var tweetCount int
-
 		var instructions []TweetInstructions
 		if apiType == APIStandart {
 			instructions = obj.Timeline.Instructions
 		} else if apiType == APIGraphql {
-			if obj.Data.User != nil {
-				instructions = obj.Data.User.Result.Timeline.Timeline.Instructions
-			} else {
-				panic("todo")
-				//instructions = obj.Data.ThreadedConversationWithInjections.Instructions
-			}
+			instructions = graphqlInstructions(obj)
 		}
 
-		for _, instruction := range instructions {
-			entries := checkEntries(instruction)
-			if entries == nil {
-				continue
-			}
-
-			for _, obj := range entries {
-				entry := utils.Dict(obj)
-
-				if strings.HasPrefix(entry.M("entryId").String(), "sq-I-t-") || strings.HasPrefix(entry.M("entryId").String(), "tweet-") {
-					tweetCount += 1
-				}
-
-				if !(strings.HasPrefix(entry.M("entryId").String(), "sq-cursor-") || strings.HasPrefix(entry.M("entryId").String(), "cursor-")) {
-					continue
-				}
-
-				var entryCursor string
-				var entryCursorStop bool
-				if apiType == APIStandart {
-					entryCursor = entry.M("content").M("operation").M("cursor").M("value").String()
-					entryCursorStop = entry.M("content").M("operation").M("cursor").M("stopOnEmptyResponse").Bool()
-				} else if apiType == APIGraphql {
-					cursorContent := entry.M("content").MapInterface()
-					var itemType string
-					var entryType string
-
-					if v, ok := cursorContent["itemType"].(string); ok {
-						itemType = v
-					}
-					if v, ok := cursorContent["entryType"].(string); ok {
-						entryType = v
-					}
-					for itemType == "TimelineTimelineItem" || entryType == "TimelineTimelineItem" {
-						cursorContent = cursorContent["itemContent"].(map[string]interface{})
-					}
-					entryCursor = cursorContent["value"].(string)
-					if v, ok := cursorContent["stopOnEmptyResponse"]; ok {
-						entryCursorStop = v.(bool)
-					}
-
-					if entryCursor == "" {
-						c.config.Logger.Debug(beginAt, "emtpty cursor")
-					}
-				}
-
-				if entry.M("entryId").String() == "sq-cursor-bottom" || strings.HasPrefix(entry.M("entryId").String(), "cursor-bottom-") {
-					newCursor = entryCursor
-					stopOnEmptyResponse = entryCursorStop
-				} else if strings.HasPrefix(entry.M("entryId").String(), "cursor-showMoreThreadsPrompt-") {
-					promptCursor = entryCursor
-					panic("prompt")
-				} else if bottomCursorAndStop == nil && (entry.M("entryId").String() == "sq-cursor-bottom" || strings.HasPrefix(entry.M("entryId").String(), "cursor-bottom-")) {
-					newBottomCursorAndStop = entryCursor
-					panic(entryCursor)
-					//entryCursorStop.(bool) = true
-				}
-			}
+		page, err := scanCursors(instructions, apiType)
+		if err != nil {
+			sendResult(ctx, channel, &TweetResult{Error: err})
+			return
 		}
-
-		if bottomCursorAndStop == nil && newBottomCursorAndStop != nil {
-			panic(newBottomCursorAndStop)
+		newCursor, promptCursor, tweetCount := page.bottom, page.prompt, page.tweets
+		if newCursor != "" {
+			stopOnEmptyResponse = page.stopOnEmpty
 		}
+
+		var done bool
 		if newCursor == cursor && tweetCount == 0 {
 			emptyResponseOnCursor += 1
-			if emptyResponseOnCursor > c.scraper.retries {
-				break
-			}
+			done = emptyResponseOnCursor > c.scraper.retries
 		}
 
-		if newCursor == "" || (stopOnEmptyResponse && tweetCount == 0) {
+		if !done && (newCursor == "" || (stopOnEmptyResponse && tweetCount == 0)) {
 			// end of pagination
 			if promptCursor != "" {
 				newCursor = promptCursor
-			} else if bottomCursorAndStop != nil {
-				panic("bottomCursorAndStop")
 			} else {
-				break
+				done = true
 			}
 		}
 
+		if cp != nil {
+			cp.Cursor, cp.Count, cp.LastTweetID, cp.Done = newCursor, tweetNum, lastTweetID, done
+			c.saveCheckpoint(cp)
+		}
+		if done {
+			break
+		}
+
 		cursor = newCursor
 		reqParams = paginationParams
 		reqParams.Set("cursor", cursor)
 	}
 }
 
+// cursorPage holds the pagination state found in one page of instructions.
+type cursorPage struct {
+	bottom      string
+	prompt      string
+	stopOnEmpty bool
+	// tweets counts the result entries, tweets or users.
+	tweets int
+}
+
+// scanCursors counts the tweet entries of a page and extracts its bottom and
+// showMoreThreadsPrompt cursors.
+func scanCursors(instructions []TweetInstructions, apiType VersionAPI) (page cursorPage, err error) {
+	var entryID string
+	defer func() {
+		if r := recover(); r != nil {
+			err = newError(ErrSchemaChanged, "cursor", "entry %s: %v", entryID, r)
+		}
+	}()
+
+	for _, instruction := range instructions {
+		entries := checkEntries(instruction)
+		if entries == nil {
+			continue
+		}
+
+		for _, obj := range entries {
+			entry := utils.Dict(obj)
+			entryID = entry.M("entryId").String()
+
+			if strings.HasPrefix(entryID, "sq-I-t-") || strings.HasPrefix(entryID, "tweet-") ||
+				strings.HasPrefix(entryID, "sq-I-u-") || strings.HasPrefix(entryID, "user-") {
+				page.tweets += 1
+			}
+
+			if !(strings.HasPrefix(entryID, "sq-cursor-") || strings.HasPrefix(entryID, "cursor-")) {
+				continue
+			}
+
+			var entryCursor string
+			var entryCursorStop bool
+			if apiType == APIStandart {
+				cursor := entry.M("content").M("operation").M("cursor")
+				entryCursor = cursor.M("value").String()
+				entryCursorStop, _ = cursor.M("stopOnEmptyResponse").Interface().(bool)
+			} else if apiType == APIGraphql {
+				entryCursor, entryCursorStop = graphqlCursor(entry.M("content").MapInterface())
+			}
+
+			if entryID == "sq-cursor-bottom" || strings.HasPrefix(entryID, "cursor-bottom-") {
+				page.bottom = entryCursor
+				page.stopOnEmpty = entryCursorStop
+			} else if strings.HasPrefix(entryID, "cursor-showMoreThreadsPrompt-") {
+				page.prompt = entryCursor
+			}
+		}
+	}
+	return page, nil
+}
+
+// graphqlCursor unwraps a GraphQL cursor entry content down to its value.
+func graphqlCursor(content map[string]interface{}) (string, bool) {
+	for content["itemType"] == "TimelineTimelineItem" || content["entryType"] == "TimelineTimelineItem" {
+		content = content["itemContent"].(map[string]interface{})
+	}
+	value, _ := content["value"].(string)
+	stop, _ := content["stopOnEmptyResponse"].(bool)
+	return value, stop
+}
+
+// graphqlInstructions returns the instructions of a user timeline or, for
+// TweetDetail responses, of the threaded conversation.
+func graphqlInstructions(obj twitterResponse) []TweetInstructions {
+	if obj.Data.User != nil {
+		return obj.Data.User.Result.Timeline.Timeline.Instructions
+	}
+	return obj.Data.ThreadedConversationWithInjections.Instructions
+}
+
 func (c *TwitterScraper) TweetSearch(ctx context.Context, query string, maxTweets int) <-chan *TweetResult {
 	channel := make(chan *TweetResult)
 
-	c.query = query
-	query, paginationParams := c.params(query)
+	if c.config.SearchTab == TabPeople {
+		return errorChannel(newError(ErrInvalidQuery, "search", "the people tab lists accounts, use UserSearch"))
+	}
+
+	since, until := c.dateWindow()
+	if c.config.Sharding != nil {
+		if since.IsZero() {
+			return errorChannel(newError(ErrInvalidQuery, "search", "sharding needs Config.Date"))
+		}
+		if regexQueryDates.MatchString(query) {
+			return errorChannel(newError(ErrInvalidQuery, "search", "sharding splits Config.Date, the query cannot set its own dates"))
+		}
+		return c.shardedSearch(ctx, query, since, until, maxTweets)
+	}
+	paginationParams := c.searchParams(query, c.config.SearchTab, since, until)
+
+	// copy value
+	params := paginationParams
+	params.Del("cursor")
+
+	cp := c.newCheckpoint(CheckpointSearch, query, c.endpoints.Search+"?", paginationParams, maxTweets)
+	go c.iteratorApiData(ctx, c.endpoints.Search+"?", params, paginationParams, "", maxTweets, APIStandart, channel, parseTimeline, cp)
+	return channel
+}
+
+// searchParams returns the adaptive.json parameters of a search in tab,
+// limited to [since, until) unless they are zero.
+func (c *TwitterScraper) searchParams(query string, tab SearchTab, since, until time.Time) url.Values {
+	query, paginationParams := c.params(query, since, until)
 	paginationParams.Add("q", query)
-	//paginationParams.Add("f", "top")
 	paginationParams.Add("include_profile_interstitial_type", "1")
 	paginationParams.Add("include_blocking", "1")
 	paginationParams.Add("include_blocked_by", "1")
@@ -394,89 +571,85 @@ func (c *TwitterScraper) TweetSearch(ctx context.Context, query string, maxTweet
 	paginationParams.Add("include_ext_media_availability", "true")
 	paginationParams.Add("send_error_codes", "true")
 	paginationParams.Add("simple_quoted_tweets", "true")
-	paginationParams.Add("tweet_search_mode", "live")
 	paginationParams.Add("count", "100")
 	paginationParams.Add("query_source", "spelling_expansion_revert_click")
 	paginationParams.Add("cursor", "")
 	paginationParams.Add("pc", "1")
 	paginationParams.Add("spelling_corrections", "1")
 	paginationParams.Add("ext", "mediaStats,highlightedLabel")
-
-	// copy value
-	params := paginationParams
-	params.Del("cursor")
-
-	go c.iteratorApiData(ctx, TwitterAPISearch+"?", params, paginationParams, "", maxTweets, APIStandart, channel, parseTimeline)
-	return channel
+	tab.apply(paginationParams)
+	return paginationParams
 }
 
-func (c *TwitterScraper) TweetUser(ctx context.Context, username string, maxTweets int) <-chan *TweetResult {
-	channel := make(chan *TweetResult)
-
-	baseUrl := "https://twitter.com/i/user/" + username
-	if err := c.ensureGuestToken(baseUrl); err != nil {
-		panic(err)
-	}
-
-	paramsStr := "variables=%7B%22screen_name%22%3A%22" + username + "%22%2C%22withSafetyModeUserFields%22%3Atrue%2C%22withSuperFollowsUserFields%22%3Atrue%7D"
-	resp, err := c.scraper.RequestGET(TwitterAPIUserScreenName+"?", paramsStr, c.apiHeaders, c.CheckTokenResponse)
+// TweetSearchQuery is TweetSearch for a query built with NewQuery, an
+// invalid query is reported on the channel. Since, Until and Lang of the
+// query take the place of Config.Date and Config.Lang.
+func (c *TwitterScraper) TweetSearchQuery(ctx context.Context, q *QueryBuilder, maxTweets int) <-chan *TweetResult {
+	query, err := q.Build()
 	if err != nil {
-		panic(err)
+		return errorChannel(err)
 	}
-	defer resp.Body.Close()
+	return c.TweetSearch(ctx, query, maxTweets)
+}
 
-	var result TweetGraphqlUser
-	err = json.NewDecoder(resp.Body).Decode(&result)
+func (c *TwitterScraper) TweetUser(ctx context.Context, username string, maxTweets int) <-chan *TweetResult {
+
+	user, err := c.GetUser(ctx, username)
 	if err != nil {
-		panic(err)
-	}
-	if result.Data.User.Result.Typename == "UserUnavailable" {
-		return channel
+		return errorChannel(err)
 	}
 
-	// TODO
-	user := result.Data.User.Result
-	//rawDescription := strings.Join([]string{user.Legacy.Description, strings.Join(renderTextWithUrls(user.Legacy.Description, user.Legacy.Entities.Description.Urls), ", ")}, " | ")
-	//fmt.Println(rawDescription)
-
+	// the other variables come from the operation, see Endpoints
 	paginationVariables := url.Values{}
-	paginationVariables.Add("userId", user.RestID)
-	paginationVariables.Add("count", "100")
+	paginationVariables.Add("userId", strconv.Itoa(user.Id))
 	paginationVariables.Add("cursor", "")
-	paginationVariables.Add("includePromotedContent", "true")
-	paginationVariables.Add("withCommunity", "true")
-	paginationVariables.Add("withSuperFollowsUserFields", "true")
-	paginationVariables.Add("withDownvotePerspective", "false")
-	paginationVariables.Add("withReactionsMetadata", "false")
-	paginationVariables.Add("withReactionsPerspective", "false")
-	paginationVariables.Add("withSuperFollowsTweetFields", "true")
-	paginationVariables.Add("withVoice", "true")
-	paginationVariables.Add("withV2Timeline", "false")
 
 	variables := paginationVariables
 	variables.Del("cursor")
 
-	//paginationParams.Add("screen_name", username)
-	//paginationParams.Add("withSafetyModeUserFields", "true")
-	//paginationParams.Add("withSuperFollowsUserFields", "true")
-	//paramsStr = "variables=%7B%22screen_name%22%3A%22" + username + "%22%2C%22withSafetyModeUserFields%22%3Atrue%2C%22withSuperFollowsUserFields%22%3Atrue%7D"
+	channel := make(chan *TweetResult)
+	endpoint := c.endpoints.graphqlURL(OpUserTweets) + "?"
+	cp := c.newCheckpoint(CheckpointUser, username, endpoint, paginationVariables, maxTweets)
+	go c.iteratorApiData(ctx, endpoint, variables, paginationVariables, "", maxTweets, APIGraphql, channel, parseTimelineV2, cp)
+	return channel
+}
 
-	go c.iteratorApiData(ctx, TwitterAPIUserTweets+"?", variables, paginationVariables, "", maxTweets, APIGraphql, channel, parseTimelineV2)
+// sendResult delivers r unless ctx is cancelled first, so an iterator never
+// blocks on a consumer that stopped reading.
+func sendResult(ctx context.Context, channel chan<- *TweetResult, r *TweetResult) bool {
+	select {
+	case channel <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// errorChannel returns a closed channel holding a single error result.
+func errorChannel(err error) <-chan *TweetResult {
+	channel := make(chan *TweetResult, 1)
+	channel <- &TweetResult{Error: err}
+	close(channel)
 	return channel
 }
 
-func (c *TwitterScraper) params(query string) (string, url.Values) {
+// params adds the language of the config and the date range to query,
+// unless query has lang: or date operators of its own.
+func (c *TwitterScraper) params(query string, since, until time.Time) (string, url.Values) {
 	paginationParams := url.Values{}
-	switch c.config.Lang {
-	case LangID:
-		query = fmt.Sprintf("%s lang:%s", query, "id")
-		paginationParams.Add("lang", "id")
-	case LangEn:
-		paginationParams.Add("lang", "en")
-		query = fmt.Sprintf("%s lang:%s", query, "en")
-	}
-	if c.config.Date.Since != "" && c.config.Date.Until != "" {
-		query += fmt.Sprintf(" until:%s since:%s ", c.config.Date.Until, c.config.Date.Since)
-	}
-	return query, paginationParams
+	q := NewQuery().Raw(query)
+	lang := c.config.Lang.code()
+	paginationParams.Add("lang", lang)
+	if !regexQueryLang.MatchString(query) {
+		q.Lang(lang)
+	}
+	if !since.IsZero() && !until.IsZero() && !regexQueryDates.MatchString(query) {
+		q.Since(since).Until(until)
+	}
+	return q.String(), paginationParams
+}
+
+// dateWindow returns Config.Date as [since, until), zero when unset.
+func (c *TwitterScraper) dateWindow() (since, until time.Time) {
+	return c.scraper.since, c.scraper.until
 }
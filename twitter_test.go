@@ -0,0 +1,148 @@
+package sns_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/twittertest"
+)
+
+func TestTweetSearchRateLimited(t *testing.T) {
+	tweets := fakeTweets(5)
+	srv := twittertest.NewServer(twittertest.Config{
+		Users:  fakeUsers,
+		Tweets: tweets,
+		Faults: twittertest.Faults{RateLimited: 1},
+	})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	ids, errs := collect(t, scraper.TweetSearch(context.Background(), "from:alice", 100))
+	if len(errs) > 0 {
+		t.Fatalf("errors %v", errs)
+	}
+	if want := tweetIds(tweets); !reflect.DeepEqual(ids, want) {
+		t.Fatalf("tweets %v, want %v", ids, want)
+	}
+	// the 429, its retry and the empty last page
+	if got := srv.Requests(twittertest.EndpointSearch); got != 3 {
+		t.Fatalf("%d search requests, want 3", got)
+	}
+}
+
+func TestTweetSearchMalformedPage(t *testing.T) {
+	tweets := fakeTweets(5)
+	srv := twittertest.NewServer(twittertest.Config{
+		Users:    fakeUsers,
+		Tweets:   tweets,
+		PageSize: 2,
+		Faults:   twittertest.Faults{MalformedPage: 2},
+	})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	ids, errs := collect(t, scraper.TweetSearch(context.Background(), "from:alice", 100))
+	if want := tweetIds(tweets[:2]); !reflect.DeepEqual(ids, want) {
+		t.Fatalf("tweets %v, want those of the first page %v", ids, want)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], sns.ErrSchemaChanged) {
+		t.Fatalf("errors %v, want one ErrSchemaChanged", errs)
+	}
+	var scrapeErr *sns.ScrapeError
+	if !errors.As(errs[0], &scrapeErr) {
+		t.Fatalf("error %T, want *sns.ScrapeError", errs[0])
+	}
+	if got := srv.Requests(twittertest.EndpointSearch); got != 2 {
+		t.Fatalf("%d search requests, want 2", got)
+	}
+}
+
+func TestTombstonesSkipped(t *testing.T) {
+	tweets := fakeTweets(6)
+	tweets[1].Tombstone = true
+	tweets[4].Tombstone = true
+	srv := twittertest.NewServer(twittertest.Config{Users: fakeUsers, Tweets: tweets, PageSize: 4})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+	want := tweetIds(tweets)
+
+	tests := []struct {
+		name     string
+		tweets   func() <-chan *sns.TweetResult
+		endpoint string
+	}{
+		{"search", func() <-chan *sns.TweetResult {
+			return scraper.TweetSearch(context.Background(), "from:alice", 100)
+		}, twittertest.EndpointSearch},
+		{"user", func() <-chan *sns.TweetResult {
+			return scraper.TweetUser(context.Background(), "alice", 100)
+		}, twittertest.EndpointUserTweets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, errs := collect(t, tt.tweets())
+			if len(errs) > 0 {
+				t.Fatalf("errors %v", errs)
+			}
+			if !reflect.DeepEqual(ids, want) {
+				t.Fatalf("tweets %v, want %v", ids, want)
+			}
+			// two pages and the empty last one
+			if got := srv.Requests(tt.endpoint); got != 3 {
+				t.Fatalf("%d requests, want 3", got)
+			}
+		})
+	}
+}
+
+func TestEmptyCursorLoopEnds(t *testing.T) {
+	tweets := fakeTweets(3)
+	srv := twittertest.NewServer(twittertest.Config{
+		Users:    fakeUsers,
+		Tweets:   tweets,
+		PageSize: 2,
+		Faults:   twittertest.Faults{EmptyCursorLoop: true},
+	})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	ids, errs := collect(t, scraper.TweetSearch(context.Background(), "from:alice", 100))
+	if len(errs) > 0 {
+		t.Fatalf("errors %v", errs)
+	}
+	if want := tweetIds(tweets); !reflect.DeepEqual(ids, want) {
+		t.Fatalf("tweets %v, want %v", ids, want)
+	}
+	// two pages, then the same empty page until the retries run out
+	if got, max := srv.Requests(twittertest.EndpointSearch), 2+fastRetry.MaxAttempts+1; got < 3 || got > max {
+		t.Fatalf("%d search requests, want between 3 and %d", got, max)
+	}
+}
+
+func TestNoHTMLToken(t *testing.T) {
+	tweets := fakeTweets(3)
+	srv := twittertest.NewServer(twittertest.Config{
+		Users:  fakeUsers,
+		Tweets: tweets,
+		Faults: twittertest.Faults{NoHTMLToken: true},
+	})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	ids, errs := collect(t, scraper.TweetSearch(context.Background(), "from:alice", 100))
+	if len(errs) > 0 {
+		t.Fatalf("errors %v", errs)
+	}
+	if want := tweetIds(tweets); !reflect.DeepEqual(ids, want) {
+		t.Fatalf("tweets %v, want %v", ids, want)
+	}
+	if got := srv.Requests(twittertest.EndpointPage); got == 0 {
+		t.Fatal("the guest token page was not requested")
+	}
+	if got := srv.Requests(twittertest.EndpointToken); got != 1 {
+		t.Fatalf("%d activate.json requests, want 1", got)
+	}
+}
@@ -0,0 +1,498 @@
+// Package twittertest provides a fake twitter API on an httptest.Server, so
+// that a TwitterScraper can run end-to-end without network access:
+//
+//	srv := twittertest.NewServer(twittertest.Config{Users: users, Tweets: tweets})
+//	defer srv.Close()
+//	conf.Endpoints = srv.Endpoints()
+//	scraper, err := sns.NewTwitterScraper(conf)
+//
+// It serves the guest token HTML page, which sets a guest_id cookie,
+// activate.json, search/adaptive.json for tweets and people and the
+// UserByScreenName, UserByRestId and UserTweetsAndReplies GraphQL operations
+// with cursor pagination, and injects the failures configured in Faults.
+package twittertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sns "github.com/hinha/go-social-network"
+)
+
+// Endpoint names as counted by Server.Requests.
+const (
+	EndpointPage             = "page"
+	EndpointToken            = "activate.json"
+	EndpointSearch           = "adaptive.json"
+	EndpointUserByScreenName = "UserByScreenName"
+	EndpointUserByRestID     = "UserByRestId"
+	EndpointUserTweets       = "UserTweetsAndReplies"
+)
+
+// User is an account known to the fake server.
+type User struct {
+	ID         int
+	ScreenName string
+	Name       string
+	// Unavailable answers UserByScreenName with UserUnavailable, as for a
+	// suspended account.
+	Unavailable bool
+	// Reason of an Unavailable account, Suspended by default.
+	Reason string
+	// NftAvatar sets has_nft_avatar on the profile.
+	NftAvatar bool
+	// Legacy fields added to the profile, e.g. description or
+	// pinned_tweet_ids_str.
+	Legacy map[string]interface{}
+}
+
+// Tweet is a tweet served by the fake server.
+type Tweet struct {
+	ID        int
+	UserID    int
+	Text      string
+	CreatedAt time.Time
+	// Hashtags of the tweet, without their leading #.
+	Hashtags []string
+	// Tombstone serves the tweet as a deleted tweet placeholder.
+	Tombstone bool
+}
+
+// Faults are the failures the server injects.
+type Faults struct {
+	// RateLimited answers the first RateLimited API requests with 429.
+	RateLimited int
+	// MalformedPage answers that page of search or user tweets, counted from
+	// 1, with truncated JSON.
+	MalformedPage int
+	// EmptyCursorLoop answers every request past the last page with no
+	// tweets and the very same cursor, instead of stopping pagination.
+	EmptyCursorLoop bool
+	// NoHTMLToken leaves the guest token out of the HTML pages, so that it
+	// has to be fetched from activate.json.
+	NoHTMLToken bool
+	// BrokenUsers serves the first BrokenUsers accounts of a people search
+	// without their user, so that they fail to parse.
+	BrokenUsers int
+}
+
+// Config is the data and behaviour of a Server.
+type Config struct {
+	Users []User
+	// Tweets newest first. Search returns all of them, user tweets those of
+	// the user.
+	Tweets []Tweet
+	// PageSize tweets per page, 20 by default.
+	PageSize int
+	Faults   Faults
+}
+
+// Server is a fake twitter API. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+	conf Config
+
+	mu       sync.Mutex
+	tokens   int
+	requests map[string]int
+	params   map[string][]url.Values
+	api      int
+}
+
+// NewServer starts a server serving conf, Close it when done.
+func NewServer(conf Config) *Server {
+	if conf.PageSize <= 0 {
+		conf.PageSize = 20
+	}
+	s := &Server{conf: conf, requests: make(map[string]int), params: make(map[string][]url.Values)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Endpoints points a scraper at the server, see sns.Config.Endpoints.
+func (s *Server) Endpoints() *sns.Endpoints {
+	return &sns.Endpoints{
+		Web:     s.URL,
+		Token:   s.URL + "/1.1/guest/activate.json",
+		Search:  s.URL + "/2/search/adaptive.json",
+		GraphQL: s.URL + "/i/api/graphql",
+	}
+}
+
+// Requests returns how many requests an endpoint received, e.g.
+// EndpointSearch.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+// Params returns the query parameters of every request an endpoint
+// received, in order.
+func (s *Server) Params(endpoint string) []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]url.Values(nil), s.params[endpoint]...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := path.Base(r.URL.Path)
+	switch endpoint {
+	case EndpointToken, EndpointSearch, EndpointUserByScreenName, EndpointUserByRestID, EndpointUserTweets:
+	default:
+		endpoint = EndpointPage
+	}
+
+	s.mu.Lock()
+	s.requests[endpoint]++
+	s.params[endpoint] = append(s.params[endpoint], r.URL.Query())
+	var limited bool
+	if endpoint != EndpointPage && endpoint != EndpointToken {
+		s.api++
+		limited = s.api <= s.conf.Faults.RateLimited
+	}
+	s.mu.Unlock()
+
+	switch {
+	case endpoint == EndpointPage:
+		s.servePage(w, r)
+	case endpoint == EndpointToken:
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, map[string]string{"guest_token": s.newToken()})
+	case r.Header.Get("x-guest-token") == "":
+		writeJSON(w, errorBody(239, "Bad guest token."), http.StatusForbidden)
+	case limited:
+		w.Header().Set("x-rate-limit-limit", "180")
+		w.Header().Set("x-rate-limit-remaining", "0")
+		w.Header().Set("x-rate-limit-reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+		writeJSON(w, errorBody(88, "Rate limit exceeded."), http.StatusTooManyRequests)
+	case endpoint == EndpointSearch:
+		s.serveSearch(w, r)
+	case endpoint == EndpointUserByScreenName, endpoint == EndpointUserByRestID:
+		s.serveUser(w, r)
+	case endpoint == EndpointUserTweets:
+		s.serveUserTweets(w, r)
+	}
+}
+
+func (s *Server) newToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens++
+	return strconv.Itoa(1500000000000000000 + s.tokens)
+}
+
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// a persistent cookie like twitter's guest_id and a session cookie
+	http.SetCookie(w, &http.Cookie{Name: "guest_id", Value: "v1%3A161000000000000000", Path: "/", MaxAge: 63072000})
+	http.SetCookie(w, &http.Cookie{Name: "_twitter_sess", Value: "session", Path: "/"})
+	body := "<html><head></head><body></body></html>"
+	if !s.conf.Faults.NoHTMLToken {
+		body = fmt.Sprintf(`<html><head><script>document.cookie = decodeURIComponent("gt=%s; Max-Age=10800; Domain=.twitter.com; Path=/; Secure");</script></head><body></body></html>`, s.newToken())
+	}
+	fmt.Fprint(w, body)
+}
+
+// page returns the tweets of the page named by cursor, the cursor of the
+// next page and whether cursor is past the last page.
+func (s *Server) page(tweets []Tweet, cursor string) (page []Tweet, number int, next string, last bool) {
+	start, end, number, next, last := s.bounds(len(tweets), cursor)
+	return tweets[start:end], number, next, last
+}
+
+// bounds returns the range of the page named by cursor out of n results.
+func (s *Server) bounds(n int, cursor string) (start, end, number int, next string, last bool) {
+	if strings.HasPrefix(cursor, "page:") {
+		number, _ = strconv.Atoi(strings.TrimPrefix(cursor, "page:"))
+	}
+	start = number * s.conf.PageSize
+	if start >= n {
+		if s.conf.Faults.EmptyCursorLoop {
+			return n, n, number, cursor, false
+		}
+		return n, n, number, fmt.Sprintf("page:%d", number+1), true
+	}
+	end = start + s.conf.PageSize
+	if end > n {
+		end = n
+	}
+	return start, end, number, fmt.Sprintf("page:%d", number+1), false
+}
+
+func (s *Server) user(match func(User) bool) (User, bool) {
+	for _, u := range s.conf.Users {
+		if match(u) {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request) {
+	cursor := r.URL.Query().Get("cursor")
+	if r.URL.Query().Get("result_filter") == "user" {
+		s.serveUserSearch(w, cursor)
+		return
+	}
+	tweets, number, next, _ := s.page(s.conf.Tweets, cursor)
+	if number+1 == s.conf.Faults.MalformedPage {
+		writeMalformed(w)
+		return
+	}
+
+	globalTweets := map[string]interface{}{}
+	globalUsers := map[string]interface{}{}
+	var entries []interface{}
+	for i, t := range tweets {
+		id := strconv.Itoa(t.ID)
+		content := map[string]interface{}{"tweet": map[string]interface{}{"id": id, "displayType": "Tweet"}}
+		if t.Tombstone {
+			content = map[string]interface{}{"tombstone": map[string]interface{}{
+				"displayType":   "Inline",
+				"tombstoneInfo": map[string]interface{}{"text": "This Tweet was deleted by the Tweet author."},
+			}}
+		} else {
+			globalTweets[id] = legacyTweet(t)
+			if u, ok := s.user(func(u User) bool { return u.ID == t.UserID }); ok {
+				globalUsers[strconv.Itoa(u.ID)] = legacyUser(u)
+			}
+		}
+		entries = append(entries, map[string]interface{}{
+			"entryId":   "sq-I-t-" + id,
+			"sortIndex": strconv.Itoa(999999999 - number*s.conf.PageSize - i),
+			"content":   map[string]interface{}{"item": map[string]interface{}{"content": content}},
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"globalObjects": map[string]interface{}{"tweets": globalTweets, "users": globalUsers},
+		"timeline":      map[string]interface{}{"id": "search-6", "instructions": s.searchInstructions(cursor, next, entries)},
+	})
+}
+
+// serveUserSearch answers the people tab of search with the users.
+func (s *Server) serveUserSearch(w http.ResponseWriter, cursor string) {
+	start, end, number, next, _ := s.bounds(len(s.conf.Users), cursor)
+	globalUsers := map[string]interface{}{}
+	var entries []interface{}
+	for i, u := range s.conf.Users[start:end] {
+		id := strconv.Itoa(u.ID)
+		content := map[string]interface{}{"user": map[string]interface{}{"id": id, "displayType": "UserDetailed"}}
+		if start+i < s.conf.Faults.BrokenUsers {
+			content = map[string]interface{}{}
+		} else {
+			globalUsers[id] = legacyUser(u)
+		}
+		entries = append(entries, map[string]interface{}{
+			"entryId":   "sq-I-u-" + id,
+			"sortIndex": strconv.Itoa(999999999 - number*s.conf.PageSize - i),
+			"content":   map[string]interface{}{"item": map[string]interface{}{"content": content}},
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"globalObjects": map[string]interface{}{"tweets": map[string]interface{}{}, "users": globalUsers},
+		"timeline":      map[string]interface{}{"id": "search-6", "instructions": s.searchInstructions(cursor, next, entries)},
+	})
+}
+
+// searchInstructions adds the entries and the bottom cursor next of a search
+// page.
+func (s *Server) searchInstructions(cursor, next string, entries []interface{}) []interface{} {
+	bottom := map[string]interface{}{
+		"entryId":   "sq-cursor-bottom",
+		"sortIndex": "0",
+		"content": map[string]interface{}{"operation": map[string]interface{}{"cursor": map[string]interface{}{
+			"value":               next,
+			"cursorType":          "Bottom",
+			"stopOnEmptyResponse": !s.conf.Faults.EmptyCursorLoop,
+		}}},
+	}
+	instructions := []interface{}{}
+	if cursor == "" {
+		entries = append(entries, bottom)
+		instructions = append(instructions, map[string]interface{}{"addEntries": map[string]interface{}{"entries": entries}})
+	} else {
+		// later pages replace the cursor entries, like the real endpoint
+		if len(entries) > 0 {
+			instructions = append(instructions, map[string]interface{}{"addEntries": map[string]interface{}{"entries": entries}})
+		}
+		instructions = append(instructions, map[string]interface{}{"replaceEntry": map[string]interface{}{
+			"entryIdToReplace": "sq-cursor-bottom",
+			"entry":            bottom,
+		}})
+	}
+	return instructions
+}
+
+// variables decodes the GraphQL variables of r.
+func variables(r *http.Request) map[string]interface{} {
+	vars := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(r.URL.Query().Get("variables")), &vars)
+	return vars
+}
+
+func (s *Server) serveUser(w http.ResponseWriter, r *http.Request) {
+	vars := variables(r)
+	screenName, _ := vars["screen_name"].(string)
+	userID := fmt.Sprint(vars["userId"])
+	u, ok := s.user(func(u User) bool {
+		return strings.EqualFold(u.ScreenName, screenName) || strconv.Itoa(u.ID) == userID
+	})
+	if !ok {
+		// unknown accounts have no user at all
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{}})
+		return
+	}
+	if u.Unavailable {
+		reason := u.Reason
+		if reason == "" {
+			reason = "Suspended"
+		}
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"user": map[string]interface{}{
+			"result": map[string]interface{}{
+				"__typename":          "UserUnavailable",
+				"reason":              reason,
+				"unavailable_message": map[string]interface{}{"text": "This account is unavailable."},
+			},
+		}}})
+		return
+	}
+	writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"user": map[string]interface{}{
+		"result": graphqlUser(u),
+	}}})
+}
+
+func (s *Server) serveUserTweets(w http.ResponseWriter, r *http.Request) {
+	vars := variables(r)
+	userID, _ := strconv.Atoi(fmt.Sprint(vars["userId"]))
+	cursor, _ := vars["cursor"].(string)
+	u, ok := s.user(func(u User) bool { return u.ID == userID })
+	if !ok {
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{}})
+		return
+	}
+
+	var own []Tweet
+	for _, t := range s.conf.Tweets {
+		if t.UserID == userID {
+			own = append(own, t)
+		}
+	}
+	tweets, number, next, last := s.page(own, cursor)
+	if number+1 == s.conf.Faults.MalformedPage {
+		writeMalformed(w)
+		return
+	}
+
+	var entries []interface{}
+	for i, t := range tweets {
+		result := map[string]interface{}{"__typename": "TweetTombstone", "tombstone": map[string]interface{}{}}
+		if !t.Tombstone {
+			result = map[string]interface{}{
+				"__typename": "Tweet",
+				"rest_id":    strconv.Itoa(t.ID),
+				"core":       map[string]interface{}{"user_results": map[string]interface{}{"result": graphqlUser(u)}},
+				"legacy":     legacyTweet(t),
+			}
+		}
+		entries = append(entries, map[string]interface{}{
+			"entryId":   "tweet-" + strconv.Itoa(t.ID),
+			"sortIndex": strconv.Itoa(999999999 - number*s.conf.PageSize - i),
+			"content": map[string]interface{}{
+				"entryType": "TimelineTimelineItem",
+				"itemContent": map[string]interface{}{
+					"itemType":      "TimelineTweet",
+					"tweet_results": map[string]interface{}{"result": result},
+				},
+			},
+		})
+	}
+	entries = append(entries, map[string]interface{}{
+		"entryId":   "cursor-bottom-" + strconv.Itoa(number),
+		"sortIndex": "0",
+		"content": map[string]interface{}{
+			"entryType":           "TimelineTimelineCursor",
+			"value":               next,
+			"cursorType":          "Bottom",
+			"stopOnEmptyResponse": last,
+		},
+	})
+
+	writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"user": map[string]interface{}{
+		"result": map[string]interface{}{"__typename": "User", "timeline": map[string]interface{}{
+			"timeline": map[string]interface{}{"instructions": []interface{}{
+				map[string]interface{}{"type": "TimelineAddEntries", "entries": entries},
+			}},
+		}},
+	}}})
+}
+
+func legacyTweet(t Tweet) map[string]interface{} {
+	id := strconv.Itoa(t.ID)
+	hashtags := []interface{}{}
+	for _, tag := range t.Hashtags {
+		hashtags = append(hashtags, map[string]interface{}{"text": tag})
+	}
+	return map[string]interface{}{
+		"id_str":              id,
+		"conversation_id_str": id,
+		"created_at":          t.CreatedAt.UTC().Format(time.RubyDate),
+		"full_text":           t.Text,
+		"user_id_str":         strconv.Itoa(t.UserID),
+		"lang":                "en",
+		"source":              `<a href="https://mobile.twitter.com" rel="nofollow">Twitter Web App</a>`,
+		"entities":            map[string]interface{}{"hashtags": hashtags, "urls": []interface{}{}, "user_mentions": []interface{}{}},
+	}
+}
+
+func legacyUser(u User) map[string]interface{} {
+	legacy := map[string]interface{}{
+		"id_str":      strconv.Itoa(u.ID),
+		"screen_name": u.ScreenName,
+		"name":        u.Name,
+		"created_at":  time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RubyDate),
+	}
+	for k, v := range u.Legacy {
+		legacy[k] = v
+	}
+	return legacy
+}
+
+func graphqlUser(u User) map[string]interface{} {
+	return map[string]interface{}{
+		"__typename":     "User",
+		"id":             "VXNlcjo" + strconv.Itoa(u.ID),
+		"rest_id":        strconv.Itoa(u.ID),
+		"has_nft_avatar": u.NftAvatar,
+		"legacy":         legacyUser(u),
+	}
+}
+
+func errorBody(code int, message string) map[string]interface{} {
+	return map[string]interface{}{"errors": []interface{}{map[string]interface{}{"code": code, "message": message}}}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}, status ...int) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	if len(status) > 0 {
+		w.WriteHeader(status[0])
+	}
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeMalformed(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	fmt.Fprint(w, `{"globalObjects": {"tweets": {"1": {"id_str": "1",`)
+}
@@ -9,12 +9,19 @@ import (
 type URLParams map[string]string
 
 type (
-	// TweetResult of scrapping.
+	// TweetResult of scrapping. Error wraps one of the Err* classes; when it is
+	// ErrUnsupportedCard the TwitterPost is still set, without its card.
 	TweetResult struct {
 		*entities.TwitterPost
 		Error error
 	}
 
+	// UserResult of an account search.
+	UserResult struct {
+		*entities.TwitterUser
+		Error error
+	}
+
 	twitterResponse struct {
 		GlobalObjects struct {
 			Tweets map[string]TweetRaw   `json:"tweets"`
@@ -76,22 +83,7 @@ type TweetRaw struct {
 		UserMentions []TweetUsers `json:"user_mentions"`
 	} `json:"entities"`
 	ExtendedEntities struct {
-		Media []struct {
-			IDStr         string `json:"id_str"`
-			MediaURLHttps string `json:"media_url_https"`
-			Type          string `json:"type"`
-			VideoInfo     struct {
-				DurationMillis int `json:"duration_millis"`
-				Variants       []struct {
-					ContentType string `json:"content_type"`
-					Bitrate     int    `json:"bitrate"`
-					URL         string `json:"url"`
-				} `json:"variants"`
-			} `json:"video_info"`
-			Ext struct {
-				MediaStats map[string]interface{} `json:"mediaStats"`
-			} `json:"ext"`
-		} `json:"media"`
+		Media []TweetMedia `json:"media"`
 	} `json:"extended_entities"`
 	InReplyToStatusIDStr string        `json:"in_reply_to_status_id_str"`
 	ReplyCount           int           `json:"reply_count"`
@@ -169,7 +161,11 @@ type TweetUsers struct {
 		URL struct {
 			Urls []TweetUrls `json:"urls"`
 		} `json:"url"`
+		Description struct {
+			Urls []TweetUrls `json:"urls"`
+		} `json:"description"`
 	} `json:"entities"`
+	HasNftAvatar         bool     `json:"ext_has_nft_avatar"`
 	FavouritesCount      int      `json:"favourites_count"`
 	FollowersCount       int      `json:"followers_count"`
 	FriendsCount         int      `json:"friends_count"`
@@ -204,6 +200,46 @@ type TweetUsers struct {
 	TranslatorType      string        `json:"translator_type"`
 }
 
+// TweetMedia is a photo, video or gif of extended_entities, the same in
+// adaptive.json and GraphQL legacy tweets.
+type TweetMedia struct {
+	IDStr         string `json:"id_str"`
+	MediaKey      string `json:"media_key"`
+	MediaURLHttps string `json:"media_url_https"`
+	Type          string `json:"type"`
+	ExtAltText    string `json:"ext_alt_text"`
+	OriginalInfo  struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"original_info"`
+	Sizes     map[string]TweetMediaSize `json:"sizes"`
+	VideoInfo struct {
+		AspectRatio    []int `json:"aspect_ratio"`
+		DurationMillis int   `json:"duration_millis"`
+		Variants       []struct {
+			ContentType string `json:"content_type"`
+			Bitrate     int    `json:"bitrate"`
+			URL         string `json:"url"`
+		} `json:"variants"`
+	} `json:"video_info"`
+	ExtMediaAvailability struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	} `json:"ext_media_availability"`
+	// MediaStats of GraphQL tweets
+	MediaStats map[string]interface{} `json:"mediaStats"`
+	// Ext.MediaStats of adaptive.json tweets
+	Ext struct {
+		MediaStats map[string]interface{} `json:"mediaStats"`
+	} `json:"ext"`
+}
+
+type TweetMediaSize struct {
+	W      int    `json:"w"`
+	H      int    `json:"h"`
+	Resize string `json:"resize"`
+}
+
 type TweetUrls struct {
 	Url         string `json:"url"`
 	DisplayUrl  string `json:"display_url"`
@@ -214,54 +250,28 @@ type TweetUrls struct {
 type TweetGraphqlUser struct {
 	Data struct {
 		User struct {
-			Result struct {
-				Typename              string `json:"__typename"`
-				HasNftAvatar          bool   `json:"has_nft_avatar"`
-				ID                    string `json:"id"`
-				IsProfileTranslatable bool   `json:"is_profile_translatable"`
-				Legacy                struct {
-					CreatedAt           string `json:"created_at"`
-					DefaultProfile      bool   `json:"default_profile"`
-					DefaultProfileImage bool   `json:"default_profile_image"`
-					Description         string `json:"description"`
-					Entities            struct {
-						Description struct {
-							Urls []TweetUrls `json:"urls"`
-						} `json:"description"`
-					} `json:"entities"`
-					FastFollowersCount      int           `json:"fast_followers_count"`
-					FavouritesCount         int           `json:"favourites_count"`
-					FollowersCount          int           `json:"followers_count"`
-					FriendsCount            int           `json:"friends_count"`
-					HasCustomTimelines      bool          `json:"has_custom_timelines"`
-					IsTranslator            bool          `json:"is_translator"`
-					ListedCount             int           `json:"listed_count"`
-					Location                string        `json:"location"`
-					MediaCount              int           `json:"media_count"`
-					Name                    string        `json:"name"`
-					NormalFollowersCount    int           `json:"normal_followers_count"`
-					PinnedTweetIdsStr       []string      `json:"pinned_tweet_ids_str"`
-					ProfileBannerURL        string        `json:"profile_banner_url"`
-					ProfileImageURLHTTPS    string        `json:"profile_image_url_https"`
-					ProfileInterstitialType string        `json:"profile_interstitial_type"`
-					Protected               bool          `json:"protected"`
-					ScreenName              string        `json:"screen_name"`
-					StatusesCount           int           `json:"statuses_count"`
-					TranslatorType          string        `json:"translator_type"`
-					Verified                bool          `json:"verified"`
-					WithheldInCountries     []interface{} `json:"withheld_in_countries"`
-				} `json:"legacy"`
-				LegacyExtendedProfile struct {
-				} `json:"legacy_extended_profile"`
-				RestID              string `json:"rest_id"`
-				SuperFollowEligible bool   `json:"super_follow_eligible"`
-				SuperFollowedBy     bool   `json:"super_followed_by"`
-				SuperFollowing      bool   `json:"super_following"`
-			} `json:"result"`
+			Result TweetGraphqlUserResult `json:"result"`
 		} `json:"user"`
 	} `json:"data"`
 }
 
+// TweetGraphqlUserResult is a User, or a UserUnavailable carrying Reason.
+type TweetGraphqlUserResult struct {
+	Typename              string     `json:"__typename"`
+	HasNftAvatar          bool       `json:"has_nft_avatar"`
+	ID                    string     `json:"id"`
+	IsProfileTranslatable bool       `json:"is_profile_translatable"`
+	Legacy                TweetUsers `json:"legacy"`
+	RestID                string     `json:"rest_id"`
+	SuperFollowEligible   bool       `json:"super_follow_eligible"`
+	SuperFollowedBy       bool       `json:"super_followed_by"`
+	SuperFollowing        bool       `json:"super_following"`
+	Reason                string     `json:"reason"`
+	UnavailableMessage    struct {
+		Text string `json:"text"`
+	} `json:"unavailable_message"`
+}
+
 type TweetGraphqlEntries struct {
 	Content struct {
 		EntryType   string `json:"entryType"`
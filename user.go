@@ -0,0 +1,84 @@
+package sns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hinha/go-social-network/entities"
+)
+
+// GetUser returns the profile of the account. When the account cannot be
+// shown the error is a *UserUnavailableError.
+func (c *TwitterScraper) GetUser(ctx context.Context, screenName string) (*entities.TwitterUser, error) {
+	result, err := c.userByScreenName(ctx, screenName)
+	if err != nil {
+		return nil, err
+	}
+	return profile(result, screenName)
+}
+
+// GetUserByID returns the profile of the account id, see GetUser.
+func (c *TwitterScraper) GetUserByID(ctx context.Context, id int) (*entities.TwitterUser, error) {
+	result, err := c.lookupUser(ctx, OpUserByRestID, map[string]interface{}{"userId": strconv.Itoa(id)},
+		c.endpoints.Web+"/i/user/"+strconv.Itoa(id))
+	if err != nil {
+		return nil, err
+	}
+	return profile(result, strconv.Itoa(id))
+}
+
+func (c *TwitterScraper) userByScreenName(ctx context.Context, username string) (TweetGraphqlUser, error) {
+	return c.lookupUser(ctx, OpUserByScreenName, map[string]interface{}{"screen_name": username},
+		c.endpoints.Web+"/i/user/"+username)
+}
+
+// lookupUser runs the user operation op, the guest token is taken through
+// the baseUrl page.
+func (c *TwitterScraper) lookupUser(ctx context.Context, op string, vars map[string]interface{}, baseUrl string) (TweetGraphqlUser, error) {
+	var result TweetGraphqlUser
+
+	if err := c.ensureGuestToken(ctx, baseUrl); err != nil {
+		return result, err
+	}
+
+	endpoint := c.endpoints.graphqlURL(op) + "?"
+	paramsStr := c.endpoints.graphqlParams(endpoint, vars)
+	resp, err := c.scraper.RequestGETContext(ctx, endpoint, paramsStr, c.headers(), c.CheckTokenResponse)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if err := statusError("user", resp); err != nil {
+		if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrBlocked) {
+			c.retireGuestToken()
+		}
+		return result, err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, wrapError(ErrSchemaChanged, "user", err)
+	}
+	return result, nil
+}
+
+// profile converts the user lookup result of who.
+func profile(result TweetGraphqlUser, who string) (*entities.TwitterUser, error) {
+	user := result.Data.User.Result
+	switch {
+	case user.Typename == "UserUnavailable":
+		reason := UnavailableReason(user.Reason)
+		if reason == "" {
+			reason = ReasonNotFound
+		}
+		return nil, &UserUnavailableError{User: who, Reason: reason, Message: user.UnavailableMessage.Text}
+	case user.RestID == "":
+		return nil, &UserUnavailableError{User: who, Reason: ReasonNotFound}
+	}
+
+	parsed := parseUser(user.Legacy, 0)
+	parsed.Id, _ = strconv.Atoi(user.RestID)
+	parsed.HasNftAvatar = parsed.HasNftAvatar || user.HasNftAvatar
+	return &parsed, nil
+}
@@ -0,0 +1,124 @@
+package sns_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sns "github.com/hinha/go-social-network"
+	"github.com/hinha/go-social-network/twittertest"
+)
+
+var profileUsers = []twittertest.User{
+	{
+		ID: 1, ScreenName: "alice", Name: "Alice", NftAvatar: true,
+		Legacy: map[string]interface{}{
+			"description":           "gopher at https://t.co/abc",
+			"followers_count":       42,
+			"pinned_tweet_ids_str":  []string{"1001", "1002"},
+			"withheld_in_countries": []string{"DE", "FR"},
+			"entities": map[string]interface{}{"description": map[string]interface{}{"urls": []interface{}{
+				map[string]interface{}{"url": "https://t.co/abc", "expanded_url": "https://go.dev", "display_url": "go.dev", "indices": []int{10, 26}},
+			}}},
+		},
+	},
+	{ID: 2, ScreenName: "spam", Name: "Spam", Unavailable: true},
+	{ID: 3, ScreenName: "quiet", Name: "Quiet", Unavailable: true, Reason: "Protected"},
+}
+
+func TestGetUser(t *testing.T) {
+	srv := twittertest.NewServer(twittertest.Config{Users: profileUsers})
+	defer srv.Close()
+	scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+	byName, err := scraper.GetUser(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID, err := scraper.GetUserByID(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(byName, byID) {
+		t.Fatalf("by name %+v, by ID %+v, want the same profile", byName, byID)
+	}
+
+	u := byName
+	if u.Id != 1 || u.Username != "alice" || u.DisplayName != "Alice" || u.FollowersCount != 42 {
+		t.Fatalf("user %+v", u)
+	}
+	if u.RawDescription != "gopher at https://t.co/abc" || !reflect.DeepEqual(u.DescriptionUrls, []string{"https://go.dev"}) {
+		t.Fatalf("description %q urls %v", u.RawDescription, u.DescriptionUrls)
+	}
+	if !reflect.DeepEqual(u.PinnedTweetIds, []int{1001, 1002}) {
+		t.Fatalf("pinned %v", u.PinnedTweetIds)
+	}
+	if !reflect.DeepEqual(u.WithheldInCountries, []string{"DE", "FR"}) {
+		t.Fatalf("withheld in %v", u.WithheldInCountries)
+	}
+	if !u.HasNftAvatar {
+		t.Fatal("nft avatar not set")
+	}
+}
+
+func TestGetUserUnavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		lookup func(*sns.TwitterScraper) error
+		user   string
+		reason sns.UnavailableReason
+		// message is set for accounts the API answers UserUnavailable for
+		message bool
+	}{
+		{
+			name:    "suspended",
+			lookup:  func(s *sns.TwitterScraper) error { _, err := s.GetUser(context.Background(), "spam"); return err },
+			user:    "spam",
+			reason:  sns.ReasonSuspended,
+			message: true,
+		},
+		{
+			name:    "suspended by id",
+			lookup:  func(s *sns.TwitterScraper) error { _, err := s.GetUserByID(context.Background(), 2); return err },
+			user:    "2",
+			reason:  sns.ReasonSuspended,
+			message: true,
+		},
+		{
+			name:    "other reason as is",
+			lookup:  func(s *sns.TwitterScraper) error { _, err := s.GetUser(context.Background(), "quiet"); return err },
+			user:    "quiet",
+			reason:  "Protected",
+			message: true,
+		},
+		{
+			name:   "not found",
+			lookup: func(s *sns.TwitterScraper) error { _, err := s.GetUser(context.Background(), "nobody"); return err },
+			user:   "nobody",
+			reason: sns.ReasonNotFound,
+		},
+		{
+			name:   "not found by id",
+			lookup: func(s *sns.TwitterScraper) error { _, err := s.GetUserByID(context.Background(), 99); return err },
+			user:   "99",
+			reason: sns.ReasonNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := twittertest.NewServer(twittertest.Config{Users: profileUsers})
+			defer srv.Close()
+			scraper := newFakeScraper(t, srv, sns.Config{Retry: fastRetry})
+
+			err := tt.lookup(scraper)
+			var unavailable *sns.UserUnavailableError
+			if !errors.As(err, &unavailable) || !errors.Is(err, sns.ErrUserUnavailable) {
+				t.Fatalf("error %v, want *UserUnavailableError", err)
+			}
+			if unavailable.User != tt.user || unavailable.Reason != tt.reason || (unavailable.Message != "") != tt.message {
+				t.Fatalf("error %+v, want user %s reason %s", unavailable, tt.user, tt.reason)
+			}
+		})
+	}
+}
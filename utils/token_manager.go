@@ -1,12 +1,25 @@
 package utils
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
 	"time"
+
+	"github.com/hinha/go-social-network/store"
 )
 
+// GuestTokenMaxAge lifetime twitter announces for guest tokens (Max-Age=10800).
+const GuestTokenMaxAge = 10800 * time.Second
+
+// GuestTokenManager holds the guest token currently used by a scraper. It is
+// safe for concurrent use.
 type GuestTokenManager struct {
-	Token  string
-	timing time.Time
+	mu      sync.RWMutex
+	token   string
+	timing  time.Time
+	expires time.Time
 }
 
 func TokenManager() *GuestTokenManager {
@@ -14,19 +27,88 @@ func TokenManager() *GuestTokenManager {
 }
 
 func (t *GuestTokenManager) GetToken() string {
-	return t.Token
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.token
 }
 
+// SetToken stores a token with the default GuestTokenMaxAge lifetime.
 func (t *GuestTokenManager) SetToken(token string) {
-	t.Token = token
+	t.SetTokenExpiry(token, time.Now().Add(GuestTokenMaxAge))
+}
+
+// SetTokenExpiry stores a token valid until expires.
+func (t *GuestTokenManager) SetTokenExpiry(token string, expires time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token
 	t.timing = time.Now()
+	t.expires = expires
 }
 
 func (t *GuestTokenManager) GetTime() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 	return t.timing
 }
 
+func (t *GuestTokenManager) GetExpiry() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.expires
+}
+
+// Valid reports whether a token is set and not expired.
+func (t *GuestTokenManager) Valid() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.token != "" && time.Now().Before(t.expires)
+}
+
 func (t *GuestTokenManager) Reset() {
-	t.Token = ""
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = ""
 	t.timing = time.Time{}
+	t.expires = time.Time{}
+}
+
+// Load restores the token saved under key by Flush, if it has not expired.
+func (t *GuestTokenManager) Load(ctx context.Context, st store.Store, key string) error {
+	data, err := st.Get(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var token GuestToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return err
+	}
+	if token.Value == "" || token.Expired(time.Now()) {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token.Value
+	t.timing = token.CreatedAt
+	t.expires = token.ExpiresAt
+	return nil
+}
+
+// Flush saves the current token under key until it expires.
+func (t *GuestTokenManager) Flush(ctx context.Context, st store.Store, key string) error {
+	t.mu.RLock()
+	token := GuestToken{Value: t.token, CreatedAt: t.timing, ExpiresAt: t.expires}
+	t.mu.RUnlock()
+
+	if token.Value == "" || token.Expired(time.Now()) {
+		return st.Delete(ctx, key)
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return st.Set(ctx, key, data, time.Until(token.ExpiresAt))
 }
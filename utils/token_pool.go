@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hinha/go-social-network/store"
+)
+
+// GuestToken a guest token and its lifetime.
+type GuestToken struct {
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the token is expired at now.
+func (t GuestToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// TokenFetcher retrieves a fresh guest token and the Max-Age announced with
+// it, zero meaning GuestTokenMaxAge.
+type TokenFetcher func(ctx context.Context) (token string, maxAge time.Duration, err error)
+
+// TokenPool holds up to size guest tokens handed out round robin. Tokens are
+// refreshed in the background once they enter the last tenth of their
+// lifetime, and tokens that hit a rate limit are retired with Retire.
+// A TokenPool is safe for concurrent use and may be shared by many scrapers.
+type TokenPool struct {
+	mu         sync.Mutex
+	size       int
+	tokens     []*GuestToken
+	next       int
+	fetching   int
+	refreshing map[string]bool
+	ready      chan struct{}
+	now        func() time.Time
+	// version counts the tokens added and removed
+	version uint64
+}
+
+// NewTokenPool creates an empty pool of size tokens, at least one.
+func NewTokenPool(size int) *TokenPool {
+	if size < 1 {
+		size = 1
+	}
+	return &TokenPool{
+		size:       size,
+		refreshing: make(map[string]bool),
+		ready:      make(chan struct{}),
+		now:        time.Now,
+	}
+}
+
+// Get returns a live token. While the pool holds fewer than size tokens the
+// caller fetches a new one with fetch; when every slot is being fetched by
+// other callers it waits for them or for ctx.
+func (p *TokenPool) Get(ctx context.Context, fetch TokenFetcher) (GuestToken, error) {
+	for {
+		p.mu.Lock()
+		p.prune()
+
+		if len(p.tokens)+p.fetching < p.size {
+			p.fetching++
+			p.mu.Unlock()
+			return p.fetch(ctx, fetch, "")
+		}
+
+		if len(p.tokens) > 0 {
+			token := p.tokens[p.next%len(p.tokens)]
+			p.next++
+			if p.stale(token) && !p.refreshing[token.Value] {
+				p.refreshing[token.Value] = true
+				p.fetching++
+				go p.fetch(context.Background(), fetch, token.Value)
+			}
+			p.mu.Unlock()
+			return *token, nil
+		}
+
+		ready := p.ready
+		p.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return GuestToken{}, ctx.Err()
+		case <-ready:
+		}
+	}
+}
+
+// fetch adds a new token to the pool, replacing old when set.
+func (p *TokenPool) fetch(ctx context.Context, fetch TokenFetcher, old string) (GuestToken, error) {
+	value, maxAge, err := fetch(ctx)
+	now := p.now()
+	if maxAge <= 0 {
+		maxAge = GuestTokenMaxAge
+	}
+	token := GuestToken{Value: value, CreatedAt: now, ExpiresAt: now.Add(maxAge)}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetching--
+	if old != "" {
+		delete(p.refreshing, old)
+	}
+	if err == nil && value != "" {
+		if old != "" {
+			p.remove(old)
+		}
+		p.add(token)
+	}
+	// wake up waiters either way, one of them takes over the failed slot
+	close(p.ready)
+	p.ready = make(chan struct{})
+	return token, err
+}
+
+// Add puts a known token in the pool, e.g. one loaded from a previous run.
+// Expired tokens and tokens beyond size are ignored.
+func (p *TokenPool) Add(token GuestToken) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if token.Value == "" || token.Expired(p.now()) || len(p.tokens) >= p.size {
+		return
+	}
+	p.add(token)
+}
+
+func (p *TokenPool) add(token GuestToken) {
+	p.version++
+	for _, t := range p.tokens {
+		if t.Value == token.Value {
+			*t = token
+			return
+		}
+	}
+	p.tokens = append(p.tokens, &token)
+}
+
+// Retire removes a token that hit a rate limit or was rejected.
+func (p *TokenPool) Retire(value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.remove(value)
+}
+
+func (p *TokenPool) remove(value string) {
+	for i, t := range p.tokens {
+		if t.Value == value {
+			p.tokens = append(p.tokens[:i], p.tokens[i+1:]...)
+			p.version++
+			return
+		}
+	}
+}
+
+// Version changes whenever a token is fetched, added or retired, but not
+// when the pool merely hands out another of its tokens. Compare it to the
+// version last saved to know when the pool needs to be flushed again.
+func (p *TokenPool) Version() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.version
+}
+
+// Tokens returns a copy of the live tokens.
+func (p *TokenPool) Tokens() []GuestToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prune()
+	tokens := make([]GuestToken, 0, len(p.tokens))
+	for _, t := range p.tokens {
+		tokens = append(tokens, *t)
+	}
+	return tokens
+}
+
+func (p *TokenPool) prune() {
+	now := p.now()
+	live := p.tokens[:0]
+	for _, t := range p.tokens {
+		if !t.Expired(now) {
+			live = append(live, t)
+		}
+	}
+	p.tokens = live
+}
+
+// stale reports whether token is in the last tenth of its lifetime.
+func (p *TokenPool) stale(token *GuestToken) bool {
+	lifetime := token.ExpiresAt.Sub(token.CreatedAt)
+	return token.ExpiresAt.Sub(p.now()) < lifetime/10
+}
+
+// Load adds the tokens saved under key by Flush, skipping expired ones.
+func (p *TokenPool) Load(ctx context.Context, st store.Store, key string) error {
+	data, err := st.Get(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var tokens []GuestToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		p.Add(token)
+	}
+	return nil
+}
+
+// Flush saves the live tokens under key until the last of them expires.
+func (p *TokenPool) Flush(ctx context.Context, st store.Store, key string) error {
+	tokens := p.Tokens()
+	if len(tokens) == 0 {
+		return st.Delete(ctx, key)
+	}
+
+	var expires time.Time
+	for _, token := range tokens {
+		if token.ExpiresAt.After(expires) {
+			expires = token.ExpiresAt
+		}
+	}
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	return st.Set(ctx, key, data, time.Until(expires))
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// counter hands out the guest tokens "1", "2", ... with maxAge.
+type counter struct {
+	mu     sync.Mutex
+	n      int
+	maxAge time.Duration
+}
+
+func (c *counter) fetch(ctx context.Context) (string, time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+	return strconv.Itoa(c.n), c.maxAge, nil
+}
+
+func (c *counter) fetched() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+func get(t *testing.T, p *TokenPool, fetch TokenFetcher) string {
+	t.Helper()
+	token, err := p.Get(context.Background(), fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.Value
+}
+
+func TestTokenPoolRotation(t *testing.T) {
+	p := NewTokenPool(3)
+	c := &counter{}
+
+	var got []string
+	for i := 0; i < 7; i++ {
+		got = append(got, get(t, p, c.fetch))
+	}
+	// three tokens are fetched, then handed out round robin
+	want := []string{"1", "2", "3", "1", "2", "3", "1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("tokens %v, want %v", got, want)
+		}
+	}
+	if c.fetched() != 3 {
+		t.Fatalf("%d fetches, want 3", c.fetched())
+	}
+}
+
+func TestTokenPoolRetire(t *testing.T) {
+	p := NewTokenPool(2)
+	c := &counter{}
+	get(t, p, c.fetch)
+	get(t, p, c.fetch)
+	version := p.Version()
+
+	// handing out tokens does not change the version
+	get(t, p, c.fetch)
+	if p.Version() != version {
+		t.Fatal("version changed on a round robin pick")
+	}
+
+	p.Retire("1")
+	if p.Version() == version {
+		t.Fatal("version unchanged by a retirement")
+	}
+	version = p.Version()
+	p.Retire("unknown")
+	if p.Version() != version {
+		t.Fatal("version changed by retiring an unknown token")
+	}
+
+	// the freed slot is fetched again
+	if token := get(t, p, c.fetch); token != "3" {
+		t.Fatalf("token %s, want a new one", token)
+	}
+	tokens := p.Tokens()
+	if len(tokens) != 2 || tokens[0].Value != "2" || tokens[1].Value != "3" {
+		t.Fatalf("tokens %+v, want 2 and 3", tokens)
+	}
+}
+
+func TestTokenPoolLifetime(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	var mu sync.Mutex
+	p := NewTokenPool(1)
+	p.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	refreshed := make(chan struct{}, 1)
+	c := &counter{maxAge: 100 * time.Second}
+	fetch := func(ctx context.Context) (string, time.Duration, error) {
+		defer func() {
+			select {
+			case refreshed <- struct{}{}:
+			default:
+			}
+		}()
+		return c.fetch(ctx)
+	}
+	get(t, p, fetch)
+	<-refreshed
+
+	// in the last tenth of its lifetime the token is still handed out and
+	// replaced in the background
+	advance(95 * time.Second)
+	if token := get(t, p, fetch); token != "1" {
+		t.Fatalf("token %s, want the stale one", token)
+	}
+	<-refreshed
+	if tokens := p.Tokens(); len(tokens) != 1 || tokens[0].Value != "2" {
+		t.Fatalf("tokens %+v, want the refreshed one", tokens)
+	}
+
+	// expired tokens are dropped and loaded ones too
+	advance(time.Hour)
+	if tokens := p.Tokens(); len(tokens) != 0 {
+		t.Fatalf("tokens %+v, want none", tokens)
+	}
+	p.Add(GuestToken{Value: "old", ExpiresAt: now.Add(-time.Second)})
+	if tokens := p.Tokens(); len(tokens) != 0 {
+		t.Fatalf("tokens %+v, want the expired token ignored", tokens)
+	}
+}
+
+func TestTokenPoolWaiters(t *testing.T) {
+	p := NewTokenPool(1)
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := func(ctx context.Context) (string, time.Duration, error) {
+		close(started)
+		<-release
+		return "slow", 0, nil
+	}
+	done := make(chan string)
+	go func() {
+		token, _ := p.Get(context.Background(), slow)
+		done <- token.Value
+	}()
+	<-started
+
+	// the only slot is being fetched, a second caller waits for it or ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx, slow); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v, want the deadline", err)
+	}
+
+	waiter := make(chan string)
+	go func() {
+		token, _ := p.Get(context.Background(), slow)
+		waiter <- token.Value
+	}()
+	close(release)
+	if token := <-done; token != "slow" {
+		t.Fatalf("token %q", token)
+	}
+	if token := <-waiter; token != "slow" {
+		t.Fatalf("waiter got %q, want the fetched token", token)
+	}
+}
+
+func TestTokenPoolFailedFetch(t *testing.T) {
+	p := NewTokenPool(1)
+	failed := errors.New("no token")
+	if _, err := p.Get(context.Background(), func(ctx context.Context) (string, time.Duration, error) {
+		return "", 0, failed
+	}); !errors.Is(err, failed) {
+		t.Fatalf("error %v, want the fetch error", err)
+	}
+	// the slot is free again
+	if token := get(t, p, (&counter{}).fetch); token != "1" {
+		t.Fatalf("token %s", token)
+	}
+}